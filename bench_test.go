@@ -3,7 +3,9 @@ package expando
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -70,6 +72,145 @@ func BenchmarkExpandEnv(b *testing.B) {
 	}
 }
 
+func BenchmarkExpander_WithExactSize(b *testing.B) {
+	env := MapEnvironment{
+		"fox_speed":          "quick",
+		"canine_temperament": "lazy",
+	}
+	tmpl := strings.Repeat("the ${fox_speed|slow} ${fox_color|brown} fox jumps over the ${canine_temperament} dog\n", 10_000)
+	for _, td := range []struct {
+		name     string
+		expander *Expander
+	}{
+		{name: "default", expander: NewExpander()},
+		{name: "exact size", expander: NewExpander(WithExactSize())},
+	} {
+		b.Run(td.name, func(b *testing.B) {
+			var err error
+			b.ReportAllocs()
+			b.SetBytes(int64(len(tmpl)))
+			for i := 0; i < b.N; i++ {
+				_, err = td.expander.Expand(tmpl, env, nil)
+			}
+			if err != nil {
+				b.Fatal()
+			}
+		})
+	}
+}
+
+func BenchmarkExpander_WithTemplateCache(b *testing.B) {
+	env := MapEnvironment{
+		"fox_speed":          "quick",
+		"canine_temperament": "lazy",
+	}
+	tmpl := "the ${fox_speed|slow} ${fox_color|brown} fox jumps over the ${canine_temperament} dog"
+	for _, td := range []struct {
+		name     string
+		expander *Expander
+	}{
+		{name: "default", expander: NewExpander()},
+		{name: "cached", expander: NewExpander(WithTemplateCache(1))},
+	} {
+		b.Run(td.name, func(b *testing.B) {
+			var buf []byte
+			var err error
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf, err = td.expander.Expand(tmpl, env, buf[:0])
+			}
+			if err != nil {
+				b.Fatal()
+			}
+		})
+	}
+}
+
+func BenchmarkExpander_WithParallelism(b *testing.B) {
+	env := MapEnvironment{
+		"fox_speed":          "quick",
+		"canine_temperament": "lazy",
+	}
+	tmpl := strings.Repeat("the ${fox_speed|slow} ${fox_color|brown} fox jumps over the ${canine_temperament} dog\n", 10_000)
+	for _, n := range []int{1, 2, 4, 8} {
+		expander := NewExpander(WithParallelism(n))
+		b.Run(fmt.Sprintf("%d goroutines", n), func(b *testing.B) {
+			var err error
+			b.ReportAllocs()
+			b.SetBytes(int64(len(tmpl)))
+			for i := 0; i < b.N; i++ {
+				_, err = expander.Expand(tmpl, env, nil)
+			}
+			if err != nil {
+				b.Fatal()
+			}
+		})
+	}
+}
+
+func BenchmarkExpander_ExpandStream(b *testing.B) {
+	env := MapEnvironment{
+		"fox_speed":          "quick",
+		"canine_temperament": "lazy",
+	}
+	tmpl := strings.Repeat("the ${fox_speed|slow} ${fox_color|brown} fox jumps over the ${canine_temperament} dog\n", 10_000)
+	var expander Expander
+	var err error
+	b.ReportAllocs()
+	b.SetBytes(int64(len(tmpl)))
+	for i := 0; i < b.N; i++ {
+		err = expander.ExpandStream(io.Discard, strings.NewReader(tmpl), env)
+	}
+	if err != nil {
+		b.Fatal()
+	}
+}
+
+func BenchmarkExpandString(b *testing.B) {
+	env := MapEnvironment{
+		"HOST": "example.com",
+	}
+	for _, tmpl := range []string{`${HOST}`, `https://${HOST}`, `https://${HOST}/path`} {
+		b.Run(tmpl, func(b *testing.B) {
+			var err error
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err = ExpandString(tmpl, env)
+			}
+			if err != nil {
+				b.Fatal()
+			}
+		})
+	}
+}
+
+func BenchmarkStaticEnvironment(b *testing.B) {
+	for _, count := range []int{10, 100} {
+		values := map[string]string{}
+		var keys []string
+		for i := 0; i < count; i++ {
+			key := fmt.Sprintf("SOME_VARIABLE_%d", i)
+			values[key] = "value"
+			keys = append(keys, key)
+		}
+		keys = append(keys, "MISSING_VARIABLE")
+		for _, td := range []struct {
+			name string
+			env  Environment
+		}{
+			{name: "MapEnvironment", env: MapEnvironment(values)},
+			{name: "StaticEnvironment", env: NewStaticEnvironment(values)},
+		} {
+			b.Run(fmt.Sprintf("%s %d variables", td.name, count), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					td.env.LookupEnv(keys[i%len(keys)])
+				}
+			})
+		}
+	}
+}
+
 func Benchmark_readVarName(b *testing.B) {
 	data := `this_is_a_var_name|this is a value} this is some more text`
 	var got string
@@ -91,7 +232,7 @@ func Benchmark_readVarName(b *testing.B) {
 	}
 }
 
-func Benchmark_readDefaultValue(b *testing.B) {
+func Benchmark_skipDefaultValue(b *testing.B) {
 	b.Run("simple", func(b *testing.B) {
 		data := `this is a value} this is some more text`
 		var got string
@@ -100,7 +241,7 @@ func Benchmark_readDefaultValue(b *testing.B) {
 		b.ReportAllocs()
 		b.SetBytes(int64(len(`this is a value}`)))
 		for i := 0; i < b.N; i++ {
-			got, n, err = readDefaultValue(data)
+			got, n, _, err = skipDefaultValue(data)
 		}
 		if got != "this is a value" {
 			b.Fatal()
@@ -121,9 +262,9 @@ func Benchmark_readDefaultValue(b *testing.B) {
 		b.ReportAllocs()
 		b.SetBytes(int64(len(`{this\} is a {value\}}`)))
 		for i := 0; i < b.N; i++ {
-			got, n, err = readDefaultValue(data)
+			got, n, _, err = skipDefaultValue(data)
 		}
-		if got != "{this} is a {value}" {
+		if got != `{this\} is a {value\}` {
 			b.Fatal()
 		}
 		if n != len(`{this\} is a {value\}}`) {
@@ -134,3 +275,44 @@ func Benchmark_readDefaultValue(b *testing.B) {
 		}
 	})
 }
+
+func Benchmark_unescapeDefault(b *testing.B) {
+	data := `{this\} is a {value\}`
+	var got string
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		got = unescapeDefault(data)
+	}
+	if got != "{this} is a {value}" {
+		b.Fatal()
+	}
+}
+
+func BenchmarkTemplate_Execute(b *testing.B) {
+	env := MapEnvironment{
+		"fox_speed":          "quick",
+		"canine_temperament": "lazy",
+	}
+	text := "the ${fox_speed|slow} ${fox_color|brown} fox jumps over the ${canine_temperament} dog"
+	tmpl, err := Parse(text)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf []byte
+	b.Run("Expand", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, err = Expand(text, env, buf[:0])
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	})
+	b.Run("Execute", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf = tmpl.Execute(env, buf[:0])
+		}
+	})
+}
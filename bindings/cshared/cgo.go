@@ -0,0 +1,30 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import "C"
+
+import "unsafe"
+
+// Test files can't import "C", so the conversions the tests need are here.
+
+func cString(s string) *C.char {
+	return C.CString(s)
+}
+
+func goString(s *C.char) string {
+	return C.GoString(s)
+}
+
+func goBytes(s *C.char, n C.size_t) []byte {
+	return C.GoBytes(unsafe.Pointer(s), C.int(n))
+}
+
+func newSize() *C.size_t {
+	return new(C.size_t)
+}
+
+func newString() **C.char {
+	return new(*C.char)
+}
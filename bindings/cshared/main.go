@@ -0,0 +1,73 @@
+//go:build cgo
+// +build cgo
+
+// Command cshared builds expando as a C shared library with "go build -buildmode=c-shared". Strings returned by the
+// library are allocated with malloc and must be released with ExpandoFree.
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"unsafe"
+
+	"github.com/willabides/expando"
+)
+
+// ExpandoExpand expands tmpl against env, a JSON object of variable names to values. It returns the expanded template
+// with its length in *outLen, or NULL with *errOut set to the error message. *errOut is always written, so it is NULL
+// after a successful call even when it held an earlier error. The result is NUL terminated, but values can contain NUL
+// bytes, so use *outLen to read all of it. outLen and errOut may be NULL.
+//
+//export ExpandoExpand
+func ExpandoExpand(tmpl, env *C.char, outLen *C.size_t, errOut **C.char) *C.char {
+	if errOut != nil {
+		*errOut = nil
+	}
+	var vars expando.MapEnvironment
+	if env != nil {
+		err := json.Unmarshal([]byte(C.GoString(env)), &vars)
+		if err != nil {
+			setErr(errOut, "invalid env: "+err.Error())
+			return nil
+		}
+	}
+	result, err := expando.Expand(C.GoString(tmpl), vars, nil)
+	if err != nil {
+		setErr(errOut, err.Error())
+		return nil
+	}
+	if outLen != nil {
+		*outLen = C.size_t(len(result))
+	}
+	return (*C.char)(C.CBytes(append(result, 0)))
+}
+
+func setErr(errOut **C.char, msg string) {
+	if errOut != nil {
+		*errOut = C.CString(msg)
+	}
+}
+
+// ExpandoValidate returns NULL when tmpl is a valid template, or an error message when it isn't
+//
+//export ExpandoValidate
+func ExpandoValidate(tmpl *C.char) *C.char {
+	_, err := expando.ListVars(C.GoString(tmpl))
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// ExpandoFree releases a string returned by the library
+//
+//export ExpandoFree
+func ExpandoFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func main() {}
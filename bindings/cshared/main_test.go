@@ -0,0 +1,75 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandoExpand(t *testing.T) {
+	expand := func(tmpl, env string) (string, string) {
+		t.Helper()
+		cTmpl, cEnv := cString(tmpl), cString(env)
+		defer ExpandoFree(cTmpl)
+		defer ExpandoFree(cEnv)
+		n, errOut := newSize(), newString()
+		result := ExpandoExpand(cTmpl, cEnv, n, errOut)
+		if result == nil {
+			require.NotNil(t, *errOut)
+			defer ExpandoFree(*errOut)
+			return "", goString(*errOut)
+		}
+		defer ExpandoFree(result)
+		require.Nil(t, *errOut)
+		require.Equal(t, byte(0), goBytes(result, *n+1)[*n], "missing NUL terminator")
+		return string(goBytes(result, *n)), ""
+	}
+
+	result, errMsg := expand(`${A} ${B|x} $$`, `{"A":"a"}`)
+	require.Empty(t, errMsg)
+	require.Equal(t, "a x $", result)
+
+	// values can contain NUL
+	result, errMsg = expand(`${A}!`, `{"A":"a\u0000b"}`)
+	require.Empty(t, errMsg)
+	require.Equal(t, "a\x00b!", result)
+
+	_, errMsg = expand(`${`, `{}`)
+	require.Equal(t, `invalid syntax at position 2 of "${": unterminated`, errMsg)
+
+	_, errMsg = expand(`${A}`, `[]`)
+	require.Contains(t, errMsg, "invalid env: ")
+
+	// errOut is cleared when it is reused for a successful call
+	cTmpl, cBad := cString(`${A|x}`), cString(`${`)
+	defer ExpandoFree(cTmpl)
+	defer ExpandoFree(cBad)
+	errOut := newString()
+	require.Nil(t, ExpandoExpand(cBad, nil, nil, errOut))
+	require.NotNil(t, *errOut)
+	ExpandoFree(*errOut)
+	result1 := ExpandoExpand(cTmpl, nil, nil, errOut)
+	require.Nil(t, *errOut)
+	ExpandoFree(result1)
+
+	// env, outLen and errOut may be NULL
+	result2 := ExpandoExpand(cTmpl, nil, nil, nil)
+	require.Equal(t, "x", goString(result2))
+	ExpandoFree(result2)
+	require.Nil(t, ExpandoExpand(cBad, nil, nil, nil))
+}
+
+func TestExpandoValidate(t *testing.T) {
+	tmpl := cString(`${A|x}`)
+	defer ExpandoFree(tmpl)
+	require.Nil(t, ExpandoValidate(tmpl))
+
+	bad := cString(`${1}`)
+	defer ExpandoFree(bad)
+	errMsg := ExpandoValidate(bad)
+	defer ExpandoFree(errMsg)
+	require.Equal(t, `invalid syntax at position 2 of "${1}": invalid starting character`, goString(errMsg))
+}
@@ -0,0 +1,55 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command wasm builds expando for JavaScript with GOOS=js GOARCH=wasm. It sets a global "expando" object with two
+// functions:
+//
+//	expando.expand(tmpl, env) returns {result: string} or {error: string}. env is an object of variable names to values.
+//	expando.validate(tmpl) returns null when tmpl is a valid template or an error message when it isn't.
+//
+// The program keeps running so the functions stay available.
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/willabides/expando"
+)
+
+func main() {
+	js.Global().Set("expando", js.ValueOf(map[string]interface{}{
+		"expand":   js.FuncOf(expand),
+		"validate": js.FuncOf(validate),
+	}))
+	select {}
+}
+
+func expand(_ js.Value, args []js.Value) interface{} {
+	if len(args) == 0 {
+		return map[string]interface{}{"error": "missing template"}
+	}
+	vars := expando.MapEnvironment{}
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		keys := js.Global().Get("Object").Call("keys", args[1])
+		for i := 0; i < keys.Length(); i++ {
+			key := keys.Index(i).String()
+			vars[key] = args[1].Get(key).String()
+		}
+	}
+	result, err := expando.Expand(args[0].String(), vars, nil)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	return map[string]interface{}{"result": string(result)}
+}
+
+func validate(_ js.Value, args []js.Value) interface{} {
+	if len(args) == 0 {
+		return "missing template"
+	}
+	_, err := expando.ListVars(args[0].String())
+	if err != nil {
+		return err.Error()
+	}
+	return nil
+}
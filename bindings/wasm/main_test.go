@@ -0,0 +1,30 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"syscall/js"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpand(t *testing.T) {
+	env := js.ValueOf(map[string]interface{}{"A": "a"})
+	require.Equal(t, map[string]interface{}{"result": "a x $"}, expand(js.Null(), []js.Value{
+		js.ValueOf(`${A} ${B|x} $$`), env,
+	}))
+	require.Equal(t, map[string]interface{}{"result": "x"}, expand(js.Null(), []js.Value{js.ValueOf(`${A|x}`)}))
+	require.Equal(t, map[string]interface{}{
+		"error": `invalid syntax at position 2 of "${": unterminated`,
+	}, expand(js.Null(), []js.Value{js.ValueOf(`${`), env}))
+	require.Equal(t, map[string]interface{}{"error": "missing template"}, expand(js.Null(), nil))
+}
+
+func TestValidate(t *testing.T) {
+	require.Nil(t, validate(js.Null(), []js.Value{js.ValueOf(`${A|x}`)}))
+	require.Equal(t, `invalid syntax at position 2 of "${1}": invalid starting character`,
+		validate(js.Null(), []js.Value{js.ValueOf(`${1}`)}))
+	require.Equal(t, "missing template", validate(js.Null(), nil))
+}
@@ -0,0 +1,115 @@
+package expando
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithTemplateCache makes the Expander keep the parsed form of the n templates it expanded most recently, so Expand and
+// ExpandContext don't parse the same template text again. It is for programs that expand a few templates over and over
+// without managing a Template for each of them. Templates with invalid syntax aren't cached. The cache isn't used
+// WithLimits, which measures default values as they are written, or when WithPassThroughInvalid has a warn function,
+// which is only called while parsing. There is no cache when n is less than 1.
+func WithTemplateCache(n int) Option {
+	return func(e *Expander) {
+		e.cache = nil
+		if n > 0 {
+			e.cache = newTemplateCache(n)
+		}
+	}
+}
+
+// templateCache is a least recently used cache of parsed templates keyed by their text. It is safe for concurrent use.
+type templateCache struct {
+	mu   sync.Mutex
+	size int
+	// entries has the most recently used entry at the front
+	entries *list.List
+	byText  map[string]*list.Element
+}
+
+func newTemplateCache(size int) *templateCache {
+	return &templateCache{
+		size:    size,
+		entries: list.New(),
+		byText:  make(map[string]*list.Element, size),
+	}
+}
+
+// get returns the cached Template for text or nil when it isn't cached
+func (c *templateCache) get(text string) *Template {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.byText[text]
+	if !ok {
+		return nil
+	}
+	c.entries.MoveToFront(el)
+	return el.Value.(*Template)
+}
+
+// add caches t, evicting the least recently used Template when the cache is full
+func (c *templateCache) add(t *Template) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.byText[t.text]; ok {
+		// another goroutine parsed the same text first
+		c.entries.MoveToFront(el)
+		return
+	}
+	if c.entries.Len() == c.size {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.byText, oldest.Value.(*Template).text)
+	}
+	c.byText[t.text] = c.entries.PushFront(t)
+}
+
+// useCache reports whether the Expander's template cache can be used for expansions
+func (e *Expander) useCache() bool {
+	return e.cache != nil && e.limits == nil && e.onInvalid == nil
+}
+
+// expandCached is Expand for an Expander WithTemplateCache
+func (x *expansion) expandCached(tmpl string, buf []byte) ([]byte, error) {
+	t := x.cache.get(tmpl)
+	if t == nil {
+		s := x.newScanner(tmpl)
+		var err error
+		t, err = parseTokens(&s)
+		if err != nil {
+			return nil, err
+		}
+		x.cache.add(t)
+	}
+	if x.ctx != nil {
+		err := x.ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if x.sizeHint > 0 {
+		buf = grow(buf, x.sizeHint)
+	}
+	if buf == nil && (len(t.segments) > 1 || t.segments[0].name != "") {
+		buf = make([]byte, 0, 2*len(tmpl))
+	}
+	// s holds each variable for value the way the scanner would
+	var s scanner
+	for i := range t.segments {
+		seg := &t.segments[i]
+		buf = append(buf, seg.literal...)
+		if seg.name == "" {
+			continue
+		}
+		s.name = seg.name
+		s.hasDefault = seg.hasDefault
+		s.rawDefault = seg.defaultValue
+		s.constraint = seg.constraint
+		buf = append(buf, x.value(&s)...)
+	}
+	if x.err != nil {
+		return nil, x.err
+	}
+	return buf, nil
+}
@@ -0,0 +1,77 @@
+package expando
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTemplateCache(t *testing.T) {
+	expander := NewExpander(WithTemplateCache(10))
+	for _, td := range expandTests {
+		t.Run(td.in, func(t *testing.T) {
+			// the second expansion uses the cached Template
+			for i := 0; i < 2; i++ {
+				result, err := expander.Expand(td.in, expandTestEnv, nil)
+				if td.err != nil {
+					require.Equal(t, err.Error(), td.err.Error())
+					continue
+				}
+				require.NoError(t, err)
+				require.Equal(t, td.out, string(result))
+			}
+		})
+	}
+
+	t.Run("evicts the least recently used template", func(t *testing.T) {
+		expander := NewExpander(WithTemplateCache(2))
+		cached := func() []string {
+			var texts []string
+			for el := expander.cache.entries.Front(); el != nil; el = el.Next() {
+				texts = append(texts, el.Value.(*Template).text)
+			}
+			return texts
+		}
+		for _, tmpl := range []string{`${HOME}`, `${H}`, `${HOME}`, `${this}`} {
+			_, err := expander.Expand(tmpl, expandTestEnv, nil)
+			require.NoError(t, err)
+		}
+		require.Equal(t, []string{`${this}`, `${HOME}`}, cached())
+		require.Len(t, expander.cache.byText, 2)
+
+		_, err := expander.Expand(`${`, expandTestEnv, nil)
+		require.Error(t, err)
+		require.Equal(t, []string{`${this}`, `${HOME}`}, cached())
+	})
+
+	t.Run("with options", func(t *testing.T) {
+		expander := NewExpander(
+			WithTemplateCache(10),
+			WithConstraints(),
+			WithKeepDoubleDollar(),
+			WithMissingMarker(MissingMarker),
+		)
+		for i := 0; i < 2; i++ {
+			result, err := expander.Expand(`$$ ${HOME~^/} ${missing} ${x|y}`, expandTestEnv, nil)
+			require.NoError(t, err)
+			require.Equal(t, `$$ /usr/gopher <<MISSING:missing>> y`, string(result))
+			_, err = expander.Expand(`${HOME~int}`, expandTestEnv, nil)
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := expander.ExpandContext(ctx, `plain`, expandTestEnv, nil)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("not used WithLimits", func(t *testing.T) {
+		expander := NewExpander(WithTemplateCache(10), WithLimits(Limits{MaxPlaceholders: 1}))
+		_, err := expander.Expand(`${HOME} ${H}`, expandTestEnv, nil)
+		require.Equal(t, &LimitError{Limit: "MaxPlaceholders", Max: 1}, err)
+		require.Empty(t, expander.cache.byText)
+	})
+}
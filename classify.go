@@ -0,0 +1,101 @@
+package expando
+
+// SpanKind is the kind of a Span
+type SpanKind uint8
+
+const (
+	// SpanLiteral is literal text outside of a variable
+	SpanLiteral SpanKind = iota + 1
+	// SpanSigil is the "${" that opens a variable or the "}" that closes it
+	SpanSigil
+	// SpanName is the name of a variable
+	SpanName
+	// SpanPipe is the "|" that separates a variable name from its default value
+	SpanPipe
+	// SpanDefault is literal text in a default value
+	SpanDefault
+	// SpanEscape is an escape sequence: "$$" in literal text, or "\}" or "\\" in a default value
+	SpanEscape
+)
+
+var spanKindNames = [...]string{
+	SpanLiteral: "literal",
+	SpanSigil:   "sigil",
+	SpanName:    "name",
+	SpanPipe:    "pipe",
+	SpanDefault: "default",
+	SpanEscape:  "escape",
+}
+
+func (k SpanKind) String() string {
+	if int(k) < len(spanKindNames) && spanKindNames[k] != "" {
+		return spanKindNames[k]
+	}
+	return "unknown"
+}
+
+// Span is a classified byte range of a template
+type Span struct {
+	Kind SpanKind
+	// Start and End are the byte offsets of the span in the template
+	Start, End int
+}
+
+// Classify breaks tmpl into spans that cover every byte of it in order, so editors can highlight templates without
+// reimplementing the syntax. When tmpl isn't valid, Classify returns the spans up to the end of the last valid
+// variable along with the error.
+func Classify(tmpl string) ([]Span, error) {
+	var spans []Span
+	add := func(kind SpanKind, start, end int) {
+		if end > start {
+			spans = append(spans, Span{Kind: kind, Start: start, End: end})
+		}
+	}
+	s := scanner{tmpl: tmpl}
+	for {
+		start := s.pos
+		kind, err := s.next()
+		if err != nil {
+			return spans, err
+		}
+		switch kind {
+		case tokenEOF:
+			return spans, nil
+		case tokenLiteral:
+			textEnd := start + len(s.text)
+			if textEnd == s.pos {
+				add(SpanLiteral, start, s.pos)
+				continue
+			}
+			// "$$" is scanned as literal text ending with the first "$"
+			add(SpanLiteral, start, textEnd-1)
+			add(SpanEscape, textEnd-1, s.pos)
+		case tokenVar:
+			j := start + len(s.text)
+			add(SpanLiteral, start, j)
+			add(SpanSigil, j, j+2)
+			nameEnd := j + 2 + len(s.name)
+			add(SpanName, j+2, nameEnd)
+			if s.hasDefault {
+				add(SpanPipe, nameEnd, nameEnd+1)
+				addDefaultSpans(add, tmpl, nameEnd+1, s.pos-1)
+			}
+			add(SpanSigil, s.pos-1, s.pos)
+		}
+	}
+}
+
+// addDefaultSpans adds SpanDefault and SpanEscape spans for the default value in tmpl[start:end]
+func addDefaultSpans(add func(kind SpanKind, start, end int), tmpl string, start, end int) {
+	i := start
+	for j := start; j < end; j++ {
+		if tmpl[j] != '\\' {
+			continue
+		}
+		add(SpanDefault, i, j)
+		add(SpanEscape, j, j+2)
+		j++
+		i = j + 1
+	}
+	add(SpanDefault, i, end)
+}
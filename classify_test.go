@@ -0,0 +1,45 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tmpl := `a $$ ${b|x\}y} ${c|}`
+	spans, err := Classify(tmpl)
+	require.NoError(t, err)
+	var got []string
+	for _, span := range spans {
+		got = append(got, span.Kind.String()+":"+tmpl[span.Start:span.End])
+	}
+	require.Equal(t, []string{
+		"literal:a ",
+		"escape:$$",
+		"literal: ",
+		"sigil:${",
+		"name:b",
+		"pipe:|",
+		"default:x",
+		"escape:\\}",
+		"default:y",
+		"sigil:}",
+		"literal: ",
+		"sigil:${",
+		"name:c",
+		"pipe:|",
+		"sigil:}",
+	}, got)
+
+	t.Run("invalid", func(t *testing.T) {
+		spans, err := Classify(`a ${b} ${0}`)
+		require.Error(t, err)
+		require.Equal(t, []Span{
+			{Kind: SpanLiteral, Start: 0, End: 2},
+			{Kind: SpanSigil, Start: 2, End: 4},
+			{Kind: SpanName, Start: 4, End: 5},
+			{Kind: SpanSigil, Start: 5, End: 6},
+		}, spans)
+	})
+}
@@ -0,0 +1,64 @@
+package expando
+
+import (
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+// GenerateGo returns the source of a Go file in package pkgName with a function named funcName that expands tmpl.
+// The function has the signature
+//
+//	func(env interface{ LookupEnv(string) (string, bool) }) string
+//
+// so it accepts any Environment without importing expando. It writes the literal text and looks up the variables
+// directly, so there is no scanning at run time. It returns an error if tmpl isn't a valid template.
+func GenerateGo(pkgName, funcName, tmpl string) ([]byte, error) {
+	var body strings.Builder
+	size := 0
+	// literal is literal text that hasn't been written to body yet
+	literal := ""
+	writeLiteral := func() {
+		if literal != "" {
+			fmt.Fprintf(&body, "sb.WriteString(%s)\n", strconv.Quote(literal))
+			size += len(literal)
+			literal = ""
+		}
+	}
+	s := scanner{tmpl: tmpl}
+	for {
+		kind, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		if kind == tokenEOF {
+			writeLiteral()
+			break
+		}
+		literal += s.text
+		if kind != tokenVar {
+			continue
+		}
+		writeLiteral()
+		defaultValue := s.defaultValue()
+		if defaultValue == "" {
+			fmt.Fprintf(&body, "if v, ok := env.LookupEnv(%s); ok {\nsb.WriteString(v)\n}\n", strconv.Quote(s.name))
+			continue
+		}
+		fmt.Fprintf(&body, "if v, ok := env.LookupEnv(%s); ok {\nsb.WriteString(v)\n} else {\nsb.WriteString(%s)\n}\n",
+			strconv.Quote(s.name), strconv.Quote(defaultValue))
+	}
+
+	var src strings.Builder
+	src.WriteString("// Code generated by expando. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkgName)
+	src.WriteString("import \"strings\"\n\n")
+	fmt.Fprintf(&src, "// %s expands the template %s\n", funcName, strconv.Quote(tmpl))
+	fmt.Fprintf(&src, "func %s(env interface{ LookupEnv(string) (string, bool) }) string {\n", funcName)
+	src.WriteString("var sb strings.Builder\n")
+	fmt.Fprintf(&src, "sb.Grow(%d)\n", size)
+	src.WriteString(body.String())
+	src.WriteString("return sb.String()\n}\n")
+	return format.Source([]byte(src.String()))
+}
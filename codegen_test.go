@@ -0,0 +1,44 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateGo(t *testing.T) {
+	got, err := GenerateGo("config", "RenderURL", `https://${HOST|localhost}:${PORT}/$$x ${path|a\}b}`)
+	require.NoError(t, err)
+	require.Equal(t, `// Code generated by expando. DO NOT EDIT.
+
+package config
+
+import "strings"
+
+// RenderURL expands the template "https://${HOST|localhost}:${PORT}/$$x ${path|a\\}b}"
+func RenderURL(env interface{ LookupEnv(string) (string, bool) }) string {
+	var sb strings.Builder
+	sb.Grow(13)
+	sb.WriteString("https://")
+	if v, ok := env.LookupEnv("HOST"); ok {
+		sb.WriteString(v)
+	} else {
+		sb.WriteString("localhost")
+	}
+	sb.WriteString(":")
+	if v, ok := env.LookupEnv("PORT"); ok {
+		sb.WriteString(v)
+	}
+	sb.WriteString("/$x ")
+	if v, ok := env.LookupEnv("path"); ok {
+		sb.WriteString(v)
+	} else {
+		sb.WriteString("a}b")
+	}
+	return sb.String()
+}
+`, string(got))
+
+	_, err = GenerateGo("config", "Render", `${`)
+	require.EqualError(t, err, `invalid syntax at position 2 of "${": unterminated`)
+}
@@ -0,0 +1,83 @@
+package expando
+
+import "fmt"
+
+// CompatibilityWarning is a construct in a template that an Expander configured WithOSSyntax or WithPowerShellSyntax
+// expands differently than expando syntax does. It is returned by Expander.CompatibilityWarnings.
+type CompatibilityWarning struct {
+	// Start and End are the byte offsets of the construct in the template
+	Start, End int
+	// Text is the construct as it is written in the template
+	Text string
+	// Message describes how expando syntax treats the construct
+	Message string
+}
+
+// CompatibilityWarnings returns the constructs in tmpl that the Expander's compatibility syntax expands differently
+// than expando syntax, in the order they appear. Templates can be migrated a little at a time by rewriting them until
+// there are no warnings and then removing WithOSSyntax or WithPowerShellSyntax. It returns nil when neither option is
+// set and an error when tmpl isn't valid for the Expander.
+func (e *Expander) CompatibilityWarnings(tmpl string) ([]CompatibilityWarning, error) {
+	if !e.osSyntax && !e.powerShell {
+		return nil, nil
+	}
+	var warnings []CompatibilityWarning
+	s := e.newScanner(tmpl)
+	s.onInvalid = nil
+	for {
+		start := s.pos
+		kind, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		if kind == tokenEOF {
+			return warnings, nil
+		}
+		constructStart := start + len(s.text)
+		// a literal only ends before the next token when os.Expand syntax leaves invalid syntax out of the output
+		if kind == tokenLiteral && (!e.osSyntax || constructStart == s.pos) {
+			continue
+		}
+		text := tmpl[constructStart:s.pos]
+		name := ""
+		if kind == tokenVar {
+			name = s.name
+		}
+		msg := e.nativeDifference(text, name)
+		if msg == "" {
+			continue
+		}
+		warnings = append(warnings, CompatibilityWarning{
+			Start:   constructStart,
+			End:     s.pos,
+			Text:    text,
+			Message: msg,
+		})
+	}
+}
+
+// nativeDifference describes how expando syntax treats text, which compatibility syntax treats as the variable name
+// or as invalid syntax when name is empty. It returns "" when expando syntax treats text the same way.
+func (e *Expander) nativeDifference(text, name string) string {
+	s := scanner{
+		tmpl:        text,
+		keepDollars: e.keepDollars,
+		noDefaults:  e.noDefaults,
+		constraints: e.constraints != nil,
+		positional:  e.positional,
+	}
+	kind, err := s.next()
+	switch {
+	case err != nil:
+		return fmt.Sprintf("%s is invalid in expando syntax", text)
+	case kind != tokenVar || s.text != "" || s.pos != len(text):
+		return fmt.Sprintf("%s is literal text in expando syntax", text)
+	case s.name != name:
+		return fmt.Sprintf("%s is the variable %q in expando syntax", text, s.name)
+	case s.hasDefault:
+		return fmt.Sprintf("%s has a default value in expando syntax", text)
+	case s.constraint != "":
+		return fmt.Sprintf("%s has a constraint in expando syntax", text)
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpander_CompatibilityWarnings(t *testing.T) {
+	for _, td := range []struct {
+		name    string
+		options []Option
+		tmpl    string
+		want    []CompatibilityWarning
+		err     bool
+	}{
+		{
+			name: "no compatibility syntax",
+			tmpl: `$HOME ${HOME}`,
+		},
+		{
+			name:    "os syntax",
+			options: []Option{WithOSSyntax()},
+			tmpl:    `${HOME} $HOME/$$ ${a|b} ${a b} ${}x $`,
+			want: []CompatibilityWarning{
+				{Start: 8, End: 13, Text: `$HOME`, Message: `$HOME is literal text in expando syntax`},
+				{Start: 14, End: 16, Text: `$$`, Message: `$$ is literal text in expando syntax`},
+				{Start: 17, End: 23, Text: `${a|b}`, Message: `${a|b} is the variable "a" in expando syntax`},
+				{Start: 24, End: 30, Text: `${a b}`, Message: `${a b} is invalid in expando syntax`},
+				{Start: 31, End: 34, Text: `${}`, Message: `${} is invalid in expando syntax`},
+			},
+		},
+		{
+			name:    "powershell syntax",
+			options: []Option{WithPowerShellSyntax()},
+			tmpl:    `${HOME} $env:HOME ${env:Path} $$`,
+			want: []CompatibilityWarning{
+				{Start: 8, End: 17, Text: `$env:HOME`, Message: `$env:HOME is literal text in expando syntax`},
+				{Start: 18, End: 29, Text: `${env:Path}`, Message: `${env:Path} is invalid in expando syntax`},
+			},
+		},
+		{
+			name:    "invalid template",
+			options: []Option{WithPowerShellSyntax()},
+			tmpl:    `$env:HOME ${`,
+			err:     true,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			got, err := NewExpander(td.options...).CompatibilityWarnings(td.tmpl)
+			if td.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.want, got)
+		})
+	}
+}
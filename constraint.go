@@ -0,0 +1,96 @@
+package expando
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ConstraintError is returned by an Expander WithConstraints when a value doesn't satisfy its variable's constraint or
+// the constraint isn't valid.
+type ConstraintError struct {
+	// Name is the name of the variable
+	Name string
+
+	// Constraint is the constraint without escape sequences
+	Constraint string
+
+	// Err is the error from compiling Constraint when it isn't a valid regular expression
+	Err error
+}
+
+func (e *ConstraintError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid constraint %q for %s: %v", e.Constraint, e.Name, e.Err)
+	}
+	return fmt.Sprintf("value of %s doesn't match constraint %q", e.Name, e.Constraint)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// namedConstraints are the constraints that aren't regular expressions
+var namedConstraints = map[string]func(string) bool{
+	"int": func(s string) bool {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	},
+	"bool": func(s string) bool {
+		_, err := strconv.ParseBool(s)
+		return err == nil
+	},
+	"url": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	},
+}
+
+type compiledConstraint struct {
+	check func(string) bool
+	err   error
+}
+
+// checkConstraint returns a *ConstraintError when val doesn't satisfy constraint, which is written as it is in the
+// template
+func (x *expansion) checkConstraint(name, constraint string, val string) error {
+	c := x.compileConstraint(constraint)
+	if c.err != nil {
+		return &ConstraintError{Name: name, Constraint: unescapeConstraint(constraint), Err: c.err}
+	}
+	if !c.check(val) {
+		return &ConstraintError{Name: name, Constraint: unescapeConstraint(constraint)}
+	}
+	return nil
+}
+
+// compileConstraint returns the compiled constraint from the Expander's cache, compiling it the first time
+func (e *Expander) compileConstraint(constraint string) compiledConstraint {
+	if c, ok := e.constraints.Load(constraint); ok {
+		return c.(compiledConstraint)
+	}
+	var c compiledConstraint
+	unescaped := unescapeConstraint(constraint)
+	if check, ok := namedConstraints[unescaped]; ok {
+		c.check = check
+	} else {
+		var re *regexp.Regexp
+		re, c.err = regexp.Compile(unescaped)
+		if c.err == nil {
+			c.check = re.MatchString
+		}
+	}
+	e.constraints.Store(constraint, c)
+	return c
+}
+
+// unescapeConstraint removes the escape sequences "\|" and "\}" from a constraint. Other backslashes are part of the
+// regular expression.
+func unescapeConstraint(constraint string) string {
+	if !strings.Contains(constraint, `\`) {
+		return constraint
+	}
+	return strings.NewReplacer(`\|`, `|`, `\}`, `}`).Replace(constraint)
+}
@@ -0,0 +1,78 @@
+package expando
+
+import (
+	"bytes"
+	"errors"
+	"regexp/syntax"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithConstraints(t *testing.T) {
+	expander := NewExpander(WithConstraints())
+	env := MapEnvironment{
+		"PORT":  "8080",
+		"NAME":  "gopher",
+		"DEBUG": "true",
+		"URL":   "https://example.com/x",
+		"EMPTY": "",
+	}
+	for _, td := range []struct {
+		in   string
+		want string
+		err  string
+	}{
+		{in: `${PORT~^[0-9]+$|80}`, want: `8080`},
+		{in: `${missing~^[0-9]+$|80}`, want: `80`},
+		{in: `${PORT~int} ${DEBUG~bool} ${URL~url}`, want: `8080 true https://example.com/x`},
+		{in: `${NAME~^(gopher\|gordon)$}`, want: `gopher`},
+		{in: `${NAME~^[a-z]{6\}$}`, want: `gopher`},
+		{in: `${NAME~\w+}`, want: `gopher`},
+		{in: `${NAME} ${PORT|x}`, want: `gopher 8080`},
+		{in: `${NAME~int}`, err: `value of NAME doesn't match constraint "int"`},
+		{in: `${missing~int|x}`, err: `value of missing doesn't match constraint "int"`},
+		{in: `${EMPTY~.}`, err: `value of EMPTY doesn't match constraint "."`},
+		{in: `${NAME~^(a\|b)$}`, err: `value of NAME doesn't match constraint "^(a|b)$"`},
+		{in: `${NAME~url}`, err: `value of NAME doesn't match constraint "url"`},
+		{in: `${NAME~(}`, err: "invalid constraint \"(\" for NAME: error parsing regexp: missing closing ): `(`"},
+		{in: `${NAME~}`, err: `invalid syntax at position 7 of "${NAME~}": empty constraint`},
+		{in: `${NAME~abc`, err: `invalid syntax at position 10 of "${NAME~abc": unterminated`},
+		{in: `${NAME~a|\x}`, err: `invalid syntax at position 10 of "${NAME~a|\\x}": invalid escape sequence`},
+	} {
+		t.Run(td.in, func(t *testing.T) {
+			result, err := expander.Expand(td.in, env, nil)
+			var buf bytes.Buffer
+			streamErr := expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(td.in)), env)
+			if td.err != "" {
+				require.EqualError(t, err, td.err)
+				require.EqualError(t, streamErr, td.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.want, string(result))
+			require.NoError(t, streamErr)
+			require.Equal(t, td.want, buf.String())
+		})
+	}
+
+	_, err := expander.Expand(`${NAME~(}`, env, nil)
+	var syntaxErr *syntax.Error
+	require.True(t, errors.As(err, &syntaxErr))
+
+	// without WithConstraints, "~" is an invalid character
+	_, err = Expand(`${PORT~int}`, env, nil)
+	require.EqualError(t, err, `invalid syntax at position 6 of "${PORT~int": invalid character`)
+}
+
+func Test_constrainedVarInfo(t *testing.T) {
+	name, constraint, defaultValue, escaped, n, err := constrainedVarInfo(`a~x\|y\}|d\}}rest`)
+	require.NoError(t, err)
+	require.Equal(t, "a", name)
+	require.Equal(t, `x\|y\}`, constraint)
+	require.Equal(t, `d\}`, defaultValue)
+	require.True(t, escaped)
+	require.Equal(t, 13, n)
+}
@@ -0,0 +1,218 @@
+package expando
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConvertWarning describes a construct that didn't convert cleanly between template dialects
+type ConvertWarning struct {
+	// Offset is the byte offset of the construct in the input template
+	Offset  int
+	Message string
+}
+
+func (w ConvertWarning) String() string {
+	return fmt.Sprintf("offset %d: %s", w.Offset, w.Message)
+}
+
+// FromShellSyntax converts a template that uses shell or docker compose variable syntax to expando syntax. $VAR and
+// ${VAR} become ${VAR}, ${VAR-default} and ${VAR:-default} become ${VAR|default}, and "$$" stays an escaped dollar
+// sign like it is in compose files. A backslash in a default value escapes the next character like it does in the
+// shell.
+//
+// Constructs that have no expando equivalent, like ${VAR:?error} and ${VAR:+alternate}, are converted to literal text
+// and reported in the warnings along with constructs whose meaning changes, like ":-" also replacing empty values. The
+// result is always a valid expando template.
+func FromShellSyntax(tmpl string) (string, []ConvertWarning) {
+	var sb strings.Builder
+	var warnings []ConvertWarning
+	warn := func(offset int, format string, args ...interface{}) {
+		warnings = append(warnings, ConvertWarning{Offset: offset, Message: fmt.Sprintf(format, args...)})
+	}
+	// literal writes shell text that expando has no equivalent for as literal text
+	literal := func(text string) {
+		sb.WriteString(strings.ReplaceAll(text, "$", "$$"))
+	}
+	i := 0
+	for i < len(tmpl) {
+		k := strings.IndexByte(tmpl[i:], '$')
+		if k == -1 || i+k+1 == len(tmpl) {
+			sb.WriteString(tmpl[i:])
+			break
+		}
+		sb.WriteString(tmpl[i : i+k])
+		i += k
+		c := tmpl[i+1]
+		switch {
+		case c == '$':
+			sb.WriteString("$$")
+			i += 2
+		case c == '{':
+			end := closingBrace(tmpl, i+2)
+			if end == -1 {
+				warn(i, "unterminated variable")
+				literal(tmpl[i:])
+				i = len(tmpl)
+				continue
+			}
+			convertShellVar(&sb, tmpl, i, end, warn)
+			i = end + 1
+		case validNameFirstChar(c):
+			n := shellNameLen(tmpl[i+1:])
+			name := tmpl[i+1 : i+1+n]
+			if !isExpandoName(name) {
+				warn(i, "variable name %q isn't valid in expando", name)
+				literal(tmpl[i : i+1+n])
+			} else {
+				sb.WriteString("${" + name + "}")
+			}
+			i += 1 + n
+		case isShellSpecialVar(c):
+			warn(i, "special parameter $%c is literal text in expando", c)
+			literal(tmpl[i : i+2])
+			i += 2
+		default:
+			sb.WriteByte('$')
+			i++
+		}
+	}
+	return sb.String(), warnings
+}
+
+// convertShellVar writes the expando equivalent of the braced shell variable in tmpl[start:end+1]
+func convertShellVar(sb *strings.Builder, tmpl string, start, end int, warn func(offset int, format string, args ...interface{})) {
+	body := tmpl[start+2 : end]
+	n := shellNameLen(body)
+	name := body[:n]
+	op := body[n:]
+	word := ""
+	switch {
+	case strings.HasPrefix(op, ":-"), strings.HasPrefix(op, ":="):
+		word = op[2:]
+		op = op[:2]
+	case strings.HasPrefix(op, "-"), strings.HasPrefix(op, "="):
+		word = op[1:]
+		op = op[:1]
+	case op != "":
+		n = 0
+	}
+	if n == 0 || !isExpandoName(name) {
+		if n == 0 {
+			warn(start, "%q has no expando equivalent", tmpl[start:end+1])
+		} else {
+			warn(start, "variable name %q isn't valid in expando", name)
+		}
+		sb.WriteString(strings.ReplaceAll(tmpl[start:end+1], "$", "$$"))
+		return
+	}
+	switch op {
+	case "":
+		sb.WriteString("${" + name + "}")
+		return
+	case ":-":
+		warn(start, "the default for %q is only used when it is unset, not when it is empty", name)
+	case "=", ":=":
+		warn(start, "%q is not assigned its default value in expando", name)
+	}
+	word, dollar := unescapeShellWord(word)
+	if dollar {
+		warn(start, "the default for %q is literal text in expando", name)
+	}
+	sb.WriteString("${" + name + "|" + EscapeDefault(word) + "}")
+}
+
+// unescapeShellWord removes the backslashes from word like the shell does for the word in ${VAR-word}. dollar reports
+// whether word has a "$" that isn't escaped, which the shell would expand.
+func unescapeShellWord(word string) (_ string, dollar bool) {
+	if !strings.ContainsAny(word, `\$`) {
+		return word, false
+	}
+	var sb strings.Builder
+	sb.Grow(len(word))
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		switch {
+		case c == '\\' && i+1 < len(word):
+			i++
+			c = word[i]
+		case c == '$':
+			dollar = true
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String(), dollar
+}
+
+// ToShellSyntax converts an expando template to docker compose variable syntax. ${VAR} stays ${VAR}, ${VAR|default}
+// becomes ${VAR-default}, and literal dollar signs become "$$". Default values containing "}" can't be expressed in
+// compose syntax and are reported in the warnings. It returns an error when tmpl isn't a valid expando template.
+func ToShellSyntax(tmpl string) (string, []ConvertWarning, error) {
+	var sb strings.Builder
+	var warnings []ConvertWarning
+	s := scanner{tmpl: tmpl}
+	for {
+		start := s.pos
+		kind, err := s.next()
+		if err != nil {
+			return "", nil, err
+		}
+		switch kind {
+		case tokenEOF:
+			return sb.String(), warnings, nil
+		case tokenLiteral:
+			sb.WriteString(strings.ReplaceAll(s.text, "$", "$$"))
+		case tokenVar:
+			sb.WriteString(strings.ReplaceAll(s.text, "$", "$$"))
+			sb.WriteString("${" + s.name)
+			if s.hasDefault {
+				defaultValue := s.defaultValue()
+				if strings.Contains(defaultValue, "}") {
+					warnings = append(warnings, ConvertWarning{
+						Offset:  start + len(s.text),
+						Message: fmt.Sprintf(`the default for %q contains "}", which ends the variable in compose syntax`, s.name),
+					})
+				}
+				sb.WriteString("-" + strings.ReplaceAll(defaultValue, "$", "$$"))
+			}
+			sb.WriteByte('}')
+		}
+	}
+}
+
+// closingBrace returns the index of the "}" that closes a braced shell variable whose body starts at tmpl[start],
+// or -1 when there isn't one
+func closingBrace(tmpl string, start int) int {
+	depth := 1
+	for i := start; i < len(tmpl); i++ {
+		switch tmpl[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// shellNameLen returns the length of the shell variable name at the start of s
+func shellNameLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !validNameChar(c) {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// isExpandoName reports whether name, which must be made of characters from shell variable names, is a valid expando
+// variable name
+func isExpandoName(name string) bool {
+	return name != "" && validNameFirstChar(name[0])
+}
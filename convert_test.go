@@ -0,0 +1,53 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromShellSyntax(t *testing.T) {
+	for _, td := range []struct {
+		in       string
+		want     string
+		warnings []ConvertWarning
+	}{
+		{in: `$HOME/bin ${PATH} $$ $ $`, want: `${HOME}/bin ${PATH} $$ $ $`},
+		{in: `${A-x} ${B:-a}b\}`, want: `${A|x} ${B|a}b\}`, warnings: []ConvertWarning{
+			{Offset: 7, Message: `the default for "B" is only used when it is unset, not when it is empty`},
+		}},
+		{in: `${A-{x}\y}`, want: `${A|{x\}y}`},
+		{in: `${A-a\}b\\c\$B}`, want: `${A|a\}b\\c$B}`},
+		{in: `${A:?required} $1 ${_x} $_y`, want: `$${A:?required} $$1 ${_x} ${_y}`, warnings: []ConvertWarning{
+			{Offset: 0, Message: `"${A:?required}" has no expando equivalent`},
+			{Offset: 15, Message: `special parameter $1 is literal text in expando`},
+		}},
+		{in: `${A=$B}`, want: `${A|$B}`, warnings: []ConvertWarning{
+			{Offset: 0, Message: `"A" is not assigned its default value in expando`},
+			{Offset: 0, Message: `the default for "A" is literal text in expando`},
+		}},
+		{in: `a ${B`, want: `a $${B`, warnings: []ConvertWarning{
+			{Offset: 2, Message: `unterminated variable`},
+		}},
+	} {
+		t.Run(td.in, func(t *testing.T) {
+			got, warnings := FromShellSyntax(td.in)
+			require.Equal(t, td.want, got)
+			require.Equal(t, td.warnings, warnings)
+			_, err := Expand(got, MapEnvironment{}, nil)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestToShellSyntax(t *testing.T) {
+	got, warnings, err := ToShellSyntax(`${A} $$ ${B|x$y} ${C|a\}b}`)
+	require.NoError(t, err)
+	require.Equal(t, `${A} $$ ${B-x$$y} ${C-a}b}`, got)
+	require.Equal(t, []ConvertWarning{
+		{Offset: 17, Message: `the default for "C" contains "}", which ends the variable in compose syntax`},
+	}, warnings)
+
+	_, _, err = ToShellSyntax(`${0}`)
+	require.Error(t, err)
+}
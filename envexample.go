@@ -0,0 +1,39 @@
+package expando
+
+import "strings"
+
+// EnvExample returns the contents of a .env.example file for templates. It has a line for each referenced variable
+// with its default value filled in. Variables that are referenced without a default value in any template are left
+// blank with a comment saying they are required. When a variable has more than one default value, the first one by
+// template name is used and the others are listed in a comment.
+func EnvExample(templates Templates) ([]byte, error) {
+	vars, err := collectVars(templates)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for _, v := range vars {
+		if v.required {
+			sb.WriteString("# " + v.name + " is required\n")
+			sb.WriteString(v.name + "=\n")
+			continue
+		}
+		if len(v.defaults) > 1 {
+			others := make([]string, len(v.defaults)-1)
+			for i, d := range v.defaults[1:] {
+				others[i] = envQuote(d)
+			}
+			sb.WriteString("# " + v.name + " also defaults to " + strings.Join(others, ", ") + "\n")
+		}
+		sb.WriteString(v.name + "=" + envQuote(v.defaults[0]) + "\n")
+	}
+	return []byte(sb.String()), nil
+}
+
+// envQuote double quotes value when it has characters that aren't safe unquoted in a .env file
+func envQuote(value string) string {
+	if !strings.ContainsAny(value, " \t\n\r\"'\\$#`") {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`).Replace(value) + `"`
+}
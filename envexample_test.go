@@ -0,0 +1,47 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvExample(t *testing.T) {
+	got, err := EnvExample(map[string]string{
+		"b.conf": `${HOST}:${PORT|8080 # alt} ${MODE|}`,
+		"a.conf": `${PORT|80} ${GREETING|hello world}`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, `GREETING="hello world"
+# HOST is required
+HOST=
+MODE=
+# PORT also defaults to "8080 # alt"
+PORT=80
+`, string(got))
+
+	got, err = EnvExample(nil)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func Test_envQuote(t *testing.T) {
+	for _, td := range []struct {
+		in   string
+		want string
+	}{
+		{in: ``, want: ``},
+		{in: `plain`, want: `plain`},
+		{in: `hello world`, want: `"hello world"`},
+		{in: `a#b`, want: `"a#b"`},
+		{in: `$HOME`, want: `"\$HOME"`},
+		{in: `say "hi"`, want: `"say \"hi\""`},
+		{in: `it's`, want: `"it's"`},
+		{in: `a\b`, want: `"a\\b"`},
+		{in: "a\nb\r", want: `"a\nb\r"`},
+		{in: "tab\t", want: "\"tab\t\""},
+		{in: "`cmd`", want: "\"`cmd`\""},
+	} {
+		require.Equal(t, td.want, envQuote(td.in), td.in)
+	}
+}
@@ -0,0 +1,1038 @@
+package expando
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Expander expands templates the same way as Expand, with its behavior adjusted by Options. The zero value is ready to
+// use and behaves exactly like Expand.
+type Expander struct {
+	exactSize   bool
+	parallelism int
+	sizeHint    int
+	memoize     bool
+	metrics     []Metrics
+	annotate    func(name, value string) string
+	emptyUnset  bool
+	keepDollars bool
+	osSyntax    bool
+	validUTF8   bool
+	passInvalid bool
+	onInvalid   func(error)
+	denied      map[string]bool
+	deniedGlobs []string
+	limits      *Limits
+	noDefaults  bool
+	progress    func(read, written int)
+	// constraints caches compiled constraints when WithConstraints is set
+	constraints *sync.Map
+	defaults    DefaultProvider
+	fragments   map[string]string
+	powerShell  bool
+	missing     func(name string) string
+	positional  bool
+	tracer      Tracer
+	cache       *templateCache
+}
+
+// defaultExpander is used by the package level functions
+var defaultExpander Expander
+
+// Option configures an Expander
+type Option func(*Expander)
+
+// NewExpander returns an Expander configured with options
+func NewExpander(options ...Option) *Expander {
+	e := &Expander{}
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+// WithExactSize makes the Expander compute the exact size of the output before writing any of it. Each variable is
+// looked up once on the first pass and the value is reused on the second pass, so buf grows at most once. This is
+// worthwhile for very large outputs where repeated growth of buf is expensive.
+func WithExactSize() Option {
+	return func(e *Expander) {
+		e.exactSize = true
+	}
+}
+
+// WithParallelism makes the Expander split large templates into as many as n chunks that are expanded on separate
+// goroutines. Templates are only split at boundaries between variables, and each chunk is at least 64KB, so smaller
+// templates are expanded on the calling goroutine. The Environment passed to Expand must be safe for concurrent use.
+func WithParallelism(n int) Option {
+	return func(e *Expander) {
+		e.parallelism = n
+	}
+}
+
+// WithSizeHint sets the expected size of expanded output. When buf has less than n bytes of free capacity, Expand
+// grows it to fit n more bytes before it starts writing. Without a size hint, a nil buf is allocated with twice the
+// length of the template, which is too small when variable values are much longer than the variables themselves.
+func WithSizeHint(n int) Option {
+	return func(e *Expander) {
+		e.sizeHint = n
+	}
+}
+
+// WithMemoizedLookups makes the Expander remember the result of each lookup for the duration of a call to Expand or
+// ExpandStream, so a variable that appears many times in a template is only looked up once. This is worthwhile when
+// lookups are expensive, such as an Environment backed by a remote service.
+func WithMemoizedLookups() Option {
+	return func(e *Expander) {
+		e.memoize = true
+	}
+}
+
+// WithMetrics makes the Expander report statistics about each template it expands to m. It can be used more than
+// once to report to multiple Metrics. The github.com/willabides/expando/expandoprom module has a Metrics that exports
+// the statistics to Prometheus.
+func WithMetrics(m Metrics) Option {
+	return func(e *Expander) {
+		e.metrics = append(e.metrics, m)
+	}
+}
+
+// WithAnnotations makes the Expander replace each variable with annotate(name, value) instead of just the value, where
+// value is the variable's value or default value. It is for debugging large templates, where it shows which parts of
+// the output came from which variable. AnnotateInline and AnnotateHTMLComment are ready-made annotate functions.
+func WithAnnotations(annotate func(name, value string) string) Option {
+	return func(e *Expander) {
+		e.annotate = annotate
+	}
+}
+
+// AnnotateInline is an annotate function for WithAnnotations that formats variables like «name=value».
+func AnnotateInline(name, value string) string {
+	return "«" + name + "=" + value + "»"
+}
+
+// AnnotateHTMLComment is an annotate function for WithAnnotations that surrounds values with HTML comments like
+// <!-- ${name} -->value<!-- /${name} -->.
+func AnnotateHTMLComment(name, value string) string {
+	return "<!-- ${" + name + "} -->" + value + "<!-- /${" + name + "} -->"
+}
+
+// WithMissingMarker makes the Expander replace variables that are unset and have no default value with marker(name)
+// instead of an empty string, so a rendered draft shows what still needs to be provided. MissingMarker is a ready-made
+// marker function. Markers aren't checked against constraints or annotated.
+func WithMissingMarker(marker func(name string) string) Option {
+	return func(e *Expander) {
+		e.missing = marker
+	}
+}
+
+// MissingMarker is a marker function for WithMissingMarker that formats variables like <<MISSING:name>>.
+func MissingMarker(name string) string {
+	return "<<MISSING:" + name + ">>"
+}
+
+// WithEmptyAsUnset makes the Expander treat variables that are set to an empty value as unset, so ${var|default} is
+// replaced with the default value when var is empty. This is like ${var:-default} in a shell.
+func WithEmptyAsUnset() Option {
+	return func(e *Expander) {
+		e.emptyUnset = true
+	}
+}
+
+// WithKeepDoubleDollar makes the Expander leave "$$" in the template unchanged instead of replacing it with "$". This
+// is for templates with a lot of shell or Makefile text like "$$PID". There is no way to write a literal "${" in a
+// template with this option.
+func WithKeepDoubleDollar() Option {
+	return func(e *Expander) {
+		e.keepDollars = true
+	}
+}
+
+// WithOSSyntax makes the Expander parse templates the same way as os.Expand instead of with expando syntax. Variables
+// are written like $var or ${var}, there are no default values, "$$" is the variable named "$", and invalid syntax is
+// left out of the output instead of returning an error. It is for migrating from os.Expand. The osexpand package
+// wraps it in functions with the same signatures as os.Expand and os.ExpandEnv. CompatibilityWarnings reports what
+// still needs to change before a template can be expanded without it.
+func WithOSSyntax() Option {
+	return func(e *Expander) {
+		e.osSyntax = true
+	}
+}
+
+// WithPowerShellSyntax makes the Expander also replace PowerShell environment variable references formatted like
+// $env:NAME or ${env:NAME}. The variable is looked up as NAME. The "env:" prefix isn't case sensitive, and a braced name
+// can contain any character except "}". Use it to expand Windows scripts and configs with the same engine.
+// CompatibilityWarnings reports the PowerShell references in a template.
+func WithPowerShellSyntax() Option {
+	return func(e *Expander) {
+		e.powerShell = true
+	}
+}
+
+// WithPositionalArgs makes the Expander accept variable names that are all digits like ${1} or ${2|default}, so
+// command templates can be expanded against command line arguments with an ArgsEnvironment. Positional variables
+// can't have constraints.
+func WithPositionalArgs() Option {
+	return func(e *Expander) {
+		e.positional = true
+	}
+}
+
+// WithUTF8Validation makes the Expander return an *InvalidUTF8Error instead of output that isn't valid UTF-8. The value
+// of each variable is validated on its own, so the error can name the variable with an invalid value.
+func WithUTF8Validation() Option {
+	return func(e *Expander) {
+		e.validUTF8 = true
+	}
+}
+
+// WithPassThroughInvalid makes the Expander leave variables with invalid syntax in the output unchanged instead of
+// returning an error. This is for files that only resemble templates, like configs with "${" in comments. When warn
+// isn't nil, it is called with the error for each invalid variable.
+func WithPassThroughInvalid(warn func(err error)) Option {
+	return func(e *Expander) {
+		e.passInvalid = true
+		e.onInvalid = warn
+	}
+}
+
+// WithDeniedVars makes the Expander return a *DeniedVarError for templates that reference any of the variables
+// matched by patterns instead of looking them up. Patterns are variable names or globs with path.Match syntax like
+// "AWS_*". It panics if a pattern is malformed.
+func WithDeniedVars(patterns ...string) Option {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic(fmt.Sprintf("expando: invalid denied variable pattern %q: %v", pattern, err))
+		}
+	}
+	return func(e *Expander) {
+		if e.denied == nil {
+			e.denied = map[string]bool{}
+		}
+		for _, pattern := range patterns {
+			if strings.ContainsAny(pattern, `*?[\`) {
+				e.deniedGlobs = append(e.deniedGlobs, pattern)
+				continue
+			}
+			e.denied[pattern] = true
+		}
+	}
+}
+
+// WithoutDefaults disables default values, so "|" is an invalid character in a variable instead of the start of a
+// default value. This is for templates that never use defaults, where ${foo|bar} is more likely a mistake than a
+// default value. With WithPassThroughInvalid, a variable containing "|" is left in the output unchanged.
+func WithoutDefaults() Option {
+	return func(e *Expander) {
+		e.noDefaults = true
+	}
+}
+
+// WithProgress makes ExpandStream call progress after each chunk of the template is expanded with the total number of
+// bytes read from src and written to dst so far. It is for showing progress of long expansions.
+func WithProgress(progress func(read, written int)) Option {
+	return func(e *Expander) {
+		e.progress = progress
+	}
+}
+
+// WithConstraints enables constraints on variables written like ${name~constraint} or ${name~constraint|default}.
+// The value of the variable, including a default value, must satisfy the constraint or the Expander returns a
+// *ConstraintError. A constraint is either one of the named validators "int", "bool" or "url", or a regular expression
+// with regexp syntax. In a constraint, "|" and "}" must be escaped with "\|" and "\}". Compiled constraints are cached
+// for the life of the Expander.
+func WithConstraints() Option {
+	return func(e *Expander) {
+		e.constraints = &sync.Map{}
+	}
+}
+
+// DefaultProvider provides default values for variables that don't have one in the template
+type DefaultProvider interface {
+	// DefaultValue returns the default value for the variable name. It returns false when there is no default.
+	DefaultValue(name string) (string, bool)
+}
+
+// WithDefaultProvider makes the Expander get a default value from p for variables that are unset and don't have a
+// default value in the template. p is only called when a default is needed, so it can compute expensive defaults like
+// the machine's IP address on demand. A default value in the template, even an empty one like ${var|}, takes precedence.
+func WithDefaultProvider(p DefaultProvider) Option {
+	return func(e *Expander) {
+		e.defaults = p
+	}
+}
+
+// WithFragments defines named template fragments. A variable with the name of a fragment is replaced with the
+// expansion of the fragment instead of being looked up in the environment, so a fragment can be written once and
+// referenced from multiple places in a template. Fragments may reference other fragments. A fragment that references
+// itself, directly or through other fragments, causes a *FragmentCycleError. Changes to fragments after WithFragments
+// returns have no effect on the Expander.
+func WithFragments(fragments map[string]string) Option {
+	return func(e *Expander) {
+		if e.fragments == nil {
+			e.fragments = make(map[string]string, len(fragments))
+		}
+		for name, fragment := range fragments {
+			e.fragments[name] = fragment
+		}
+	}
+}
+
+// Limits are limits on the templates an Expander will expand. A limit of 0 means no limit.
+type Limits struct {
+	// MaxPlaceholders is the maximum number of variables in a template
+	MaxPlaceholders int
+
+	// MaxNameLength is the maximum length of a variable name
+	MaxNameLength int
+
+	// MaxDefaultLength is the maximum length of a default value as it is written in the template
+	MaxDefaultLength int
+}
+
+// WithLimits makes the Expander return a *LimitError for templates that exceed limits. This gives services that
+// expand untrusted templates predictable worst-case behavior. No variables are looked up after a limit is exceeded.
+func WithLimits(limits Limits) Option {
+	return func(e *Expander) {
+		e.limits = &limits
+	}
+}
+
+// Expand is equivalent to the package level Expand with the Expander's options applied.
+func (e *Expander) Expand(tmpl string, lookupEnv Environment, buf []byte) ([]byte, error) {
+	x := e.newExpansion(lookupEnv)
+	return x.expandTemplate(tmpl, buf)
+}
+
+// ExpandContext is equivalent to the package level ExpandContext with the Expander's options applied.
+func (e *Expander) ExpandContext(ctx context.Context, tmpl string, lookupEnv Environment, buf []byte) ([]byte, error) {
+	x := e.newContextExpansion(ctx, lookupEnv)
+	return x.expandTemplate(tmpl, buf)
+}
+
+// expandTemplate is Expand
+func (x *expansion) expandTemplate(tmpl string, buf []byte) ([]byte, error) {
+	start := len(buf)
+	var err error
+	switch {
+	case x.parallelism > 1 && len(tmpl) >= 2*minParallelChunkSize:
+		buf, err = x.expandParallel(tmpl, buf)
+	case x.exactSize:
+		buf, err = x.expandExact(tmpl, buf)
+	case x.useCache():
+		buf, err = x.expandCached(tmpl, buf)
+	default:
+		if x.sizeHint > 0 {
+			buf = grow(buf, x.sizeHint)
+		}
+		s := x.newScanner(tmpl)
+		buf, err = x.expand(&s, buf)
+	}
+	if err == nil {
+		err = x.checkUTF8(buf[start:], 0)
+	}
+	if err != nil {
+		x.finish(0, err)
+		return nil, err
+	}
+	x.finish(len(buf)-start, nil)
+	return buf, nil
+}
+
+// Region is a range of expanded output that a variable expanded to
+type Region struct {
+	// Name is the variable's name
+	Name string
+
+	// Start and End are the positions of the variable's value in the output
+	Start, End int
+
+	// Default means the value is the default value from the template instead of a value from the Environment
+	Default bool
+}
+
+// ExpandRegions is like Expand, but it also returns the Regions of the output that came from variables, in the order
+// they appear. Positions are indexes in the returned slice, which includes the original contents of buf. This is for
+// tools that need to redact or audit the parts of the output that came from an Environment.
+func (e *Expander) ExpandRegions(tmpl string, lookupEnv Environment, buf []byte) ([]byte, []Region, error) {
+	x := e.newExpansion(lookupEnv)
+	start := len(buf)
+	var regions []Region
+	s := e.newScanner(tmpl)
+	for {
+		kind, err := s.next()
+		if err != nil {
+			x.finish(0, err)
+			return nil, nil, err
+		}
+		if kind == tokenEOF {
+			break
+		}
+		buf = append(buf, s.text...)
+		if kind == tokenVar {
+			// value only counts a default when the template or the DefaultProvider had one
+			defaults := x.stats.Defaults
+			val := x.value(&s)
+			regions = append(regions, Region{
+				Name:    s.name,
+				Start:   len(buf),
+				End:     len(buf) + len(val),
+				Default: x.stats.Defaults > defaults,
+			})
+			buf = append(buf, val...)
+		}
+	}
+	err := x.err
+	if err == nil {
+		err = x.checkUTF8(buf[start:], 0)
+	}
+	if err != nil {
+		x.finish(0, err)
+		return nil, nil, err
+	}
+	x.finish(len(buf)-start, nil)
+	return buf, regions, nil
+}
+
+// ExpandAppendN is equivalent to the package level ExpandAppendN with the Expander's options applied.
+func (e *Expander) ExpandAppendN(dst []byte, max int, tmpl string, lookupEnv Environment) ([]byte, error) {
+	x := e.newExpansion(lookupEnv)
+	if max > cap(dst) {
+		max = cap(dst)
+	}
+	start := len(dst)
+	n := start
+	write := func(p string) {
+		if n+len(p) <= max {
+			dst = append(dst[:n], p...)
+		}
+		n += len(p)
+	}
+	s := e.newScanner(tmpl)
+	for {
+		kind, err := s.next()
+		if err != nil {
+			x.finish(0, err)
+			return dst[:start], err
+		}
+		if kind == tokenEOF {
+			break
+		}
+		write(s.text)
+		if kind == tokenVar {
+			write(x.value(&s))
+		}
+	}
+	err := x.err
+	if err == nil && n > max {
+		err = &ShortBufferError{Size: n}
+	}
+	if err == nil {
+		err = x.checkUTF8(dst[start:], 0)
+	}
+	if err != nil {
+		x.finish(0, err)
+		return dst[:start], err
+	}
+	x.finish(n-start, nil)
+	return dst, nil
+}
+
+// ExpandStream reads a template from src and writes the expanded output to dst. The template is read in chunks, so
+// memory use is bounded by the chunk size and the length of the longest variable rather than the size of the
+// template. Output from the start of the template may already have been written to dst when an error is returned.
+func (e *Expander) ExpandStream(dst io.Writer, src io.Reader, lookupEnv Environment) error {
+	x := e.newExpansion(lookupEnv)
+	written, err := x.expandStream(dst, src)
+	x.finish(written, err)
+	return err
+}
+
+// ExpandStreamContext is like ExpandStream, but it stops and returns ctx.Err() when ctx is done before the stream is
+// fully expanded.
+func (e *Expander) ExpandStreamContext(ctx context.Context, dst io.Writer, src io.Reader, lookupEnv Environment) error {
+	x := e.newContextExpansion(ctx, lookupEnv)
+	written, err := x.expandStream(dst, src)
+	x.finish(written, err)
+	return err
+}
+
+// expandStream is ExpandStream. It returns the number of bytes written to dst.
+func (x *expansion) expandStream(dst io.Writer, src io.Reader) (int, error) {
+	written := 0
+	totalRead := 0
+	in := make([]byte, streamChunkSize)
+	var out []byte
+	// n is the number of bytes of in that haven't been expanded yet
+	n := 0
+	for {
+		if x.ctx != nil {
+			err := x.ctx.Err()
+			if err != nil {
+				return written, err
+			}
+		}
+		var read int
+		var atEOF bool
+		var err error
+		in, read, atEOF, err = refillStreamBuffer(src, in, n)
+		if err != nil {
+			return written, err
+		}
+		n += read
+		totalRead += read
+		end := x.streamChunkEnd(in[:n], atEOF)
+		s := x.newScanner(string(in[:end]))
+		s.more = !atEOF
+		out, err = x.expand(&s, out[:0])
+		if err == nil {
+			err = x.checkUTF8(out, written)
+		}
+		if err != nil {
+			return written, err
+		}
+		w, err := dst.Write(out)
+		written += w
+		if err != nil {
+			return written, err
+		}
+		if x.progress != nil {
+			x.progress(totalRead, written)
+		}
+		if atEOF {
+			return written, nil
+		}
+		n = copy(in, in[s.pos:n])
+	}
+}
+
+// refillStreamBuffer reads from src into in after the first n bytes, which haven't been expanded yet. in is grown when
+// it is filled by a single variable. atEOF reports whether src is done, and err is any other error from src. Like
+// bufio, it returns io.ErrNoProgress when src returns no data and no error too many times in a row.
+func refillStreamBuffer(src io.Reader, in []byte, n int) (_ []byte, read int, atEOF bool, err error) {
+	if n == len(in) {
+		in = append(in, make([]byte, len(in))...)
+	}
+	for i := 0; i < maxConsecutiveEmptyReads; i++ {
+		read, err = src.Read(in[n:])
+		if err == io.EOF {
+			return in, read, true, nil
+		}
+		if read > 0 || err != nil {
+			return in, read, false, err
+		}
+	}
+	return in, 0, false, io.ErrNoProgress
+}
+
+// maxConsecutiveEmptyReads is how many reads from the src of ExpandStream can return no data and no error before
+// ExpandStream gives up. It is the same as bufio's.
+const maxConsecutiveEmptyReads = 100
+
+// streamChunkEnd returns how much of the unexpanded input in can be expanded now. Unless atEOF, a rune that is split
+// between reads is left for the next chunk so each chunk of output can be validated.
+func (x *expansion) streamChunkEnd(in []byte, atEOF bool) int {
+	if !x.validUTF8 || atEOF {
+		return len(in)
+	}
+	return len(in) - incompleteRuneLen(in)
+}
+
+// streamChunkSize is the size of reads from the src of ExpandStream
+const streamChunkSize = 32 * 1024
+
+// expansion holds the state of a single call to one of an Expander's methods
+type expansion struct {
+	*Expander
+	env   Environment
+	stats ExpansionStats
+	start time.Time
+	// ctx is checked between tokens when it isn't nil
+	ctx context.Context
+	// err is the first error from value
+	err error
+	// fragmentStack has the names of the fragments currently being expanded
+	fragmentStack []string
+}
+
+func (e *Expander) newExpansion(lookupEnv Environment) expansion {
+	if e.memoize {
+		lookupEnv = newMemoEnv(lookupEnv)
+	}
+	x := expansion{
+		Expander: e,
+		env:      lookupEnv,
+	}
+	if len(e.metrics) > 0 {
+		x.start = time.Now()
+	}
+	return x
+}
+
+// finish reports the expansion to the Expander's metrics
+func (x *expansion) finish(written int, err error) {
+	if len(x.metrics) == 0 {
+		return
+	}
+	x.stats.Duration = time.Since(x.start)
+	x.stats.Bytes = written
+	x.stats.Err = err
+	if m, ok := x.env.(*memoEnv); ok {
+		x.stats.Lookups = m.lookups
+		x.stats.Misses = m.misses
+	}
+	for _, m := range x.metrics {
+		m.ObserveExpansion(x.stats)
+	}
+}
+
+// expand appends the expansion of the tokens from s to buf
+func (x *expansion) expand(s *scanner, buf []byte) ([]byte, error) {
+	tmpl := s.tmpl
+	for {
+		if x.ctx != nil {
+			err := x.ctx.Err()
+			if err != nil {
+				return nil, err
+			}
+		}
+		kind, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case tokenEOF:
+			if x.err != nil {
+				return nil, x.err
+			}
+			return buf, nil
+		case tokenLiteral:
+			// preallocate when the template isn't just literal text
+			if buf == nil && s.pos != len(tmpl) {
+				buf = make([]byte, 0, 2*len(tmpl))
+			}
+			buf = append(buf, s.text...)
+		case tokenVar:
+			if buf == nil {
+				buf = make([]byte, 0, 2*len(tmpl))
+			}
+			buf = append(buf, s.text...)
+			buf = append(buf, x.value(s)...)
+		}
+	}
+}
+
+// expandExact is Expand for WithExactSize
+func (x *expansion) expandExact(tmpl string, buf []byte) ([]byte, error) {
+	var values []string
+	size := 0
+	s := x.newScanner(tmpl)
+	for {
+		kind, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		if kind == tokenEOF {
+			break
+		}
+		size += len(s.text)
+		if kind == tokenLiteral {
+			continue
+		}
+		val := x.value(&s)
+		values = append(values, val)
+		size += len(val)
+	}
+	if x.err != nil {
+		return nil, x.err
+	}
+	buf = grow(buf, size)
+
+	// The first pass succeeded, so there are no errors to check for.
+	s = x.newScanner(tmpl)
+	s.onInvalid = nil
+	for {
+		kind, _ := s.next() //nolint:errcheck // checked in the first pass
+		switch kind {
+		case tokenEOF:
+			return buf, nil
+		case tokenLiteral:
+			buf = append(buf, s.text...)
+		case tokenVar:
+			buf = append(buf, s.text...)
+			buf = append(buf, values[0]...)
+			values = values[1:]
+		}
+	}
+}
+
+// minParallelChunkSize is the smallest chunk of a template WithParallelism will expand on its own goroutine
+const minParallelChunkSize = 64 * 1024
+
+// expandParallel is Expand for WithParallelism.
+func (x *expansion) expandParallel(tmpl string, buf []byte) ([]byte, error) {
+	bounds, err := x.chunkBounds(tmpl, x.parallelism)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([][]byte, len(bounds)-1)
+	// each goroutine counts its own stats
+	stats := make([]ExpansionStats, len(chunks))
+	// chunkBounds already checked the whole template for syntax errors, so these are errors from values
+	errs := make([]error, len(chunks))
+	var wg sync.WaitGroup
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int, cx expansion) {
+			defer wg.Done()
+			s := cx.newScanner(tmpl[bounds[i]:bounds[i+1]])
+			// chunkBounds already reported invalid variables
+			s.onInvalid = nil
+			chunks[i], errs[i] = cx.expand(&s, nil)
+			stats[i] = cx.stats
+		}(i, *x)
+	}
+	wg.Wait()
+	for i := range stats {
+		x.stats.add(stats[i])
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+	}
+	// chunkBounds only counted the template's own placeholders, and each chunk only counted the placeholders in
+	// the fragments it expanded
+	if x.limits != nil && x.limits.MaxPlaceholders > 0 && x.stats.Placeholders > x.limits.MaxPlaceholders {
+		return nil, &LimitError{Limit: "MaxPlaceholders", Max: x.limits.MaxPlaceholders}
+	}
+	size := 0
+	for _, chunk := range chunks {
+		size += len(chunk)
+	}
+	buf = grow(buf, size)
+	for _, chunk := range chunks {
+		buf = append(buf, chunk...)
+	}
+	return buf, nil
+}
+
+// chunkBounds splits tmpl into as many as n chunks of roughly equal size. Chunks only start and end on boundaries
+// between tokens, so each can be scanned independently. The returned slice holds the start of each chunk followed by
+// len(tmpl). It returns an error if tmpl isn't a valid template or has more variables than the MaxPlaceholders
+// limit, so no chunk is expanded when the template as a whole is over the limit.
+func (e *Expander) chunkBounds(tmpl string, n int) ([]int, error) {
+	chunkSize := len(tmpl) / n
+	if chunkSize < minParallelChunkSize {
+		chunkSize = minParallelChunkSize
+	}
+	maxPlaceholders := 0
+	if e.limits != nil {
+		maxPlaceholders = e.limits.MaxPlaceholders
+	}
+	placeholders := 0
+	bounds := []int{0}
+	s := e.newScanner(tmpl)
+	for {
+		kind, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		if kind == tokenEOF {
+			break
+		}
+		if kind == tokenVar {
+			placeholders++
+			if maxPlaceholders > 0 && placeholders > maxPlaceholders {
+				return nil, &LimitError{Limit: "MaxPlaceholders", Max: maxPlaceholders}
+			}
+		}
+		if len(bounds) < n && s.pos-bounds[len(bounds)-1] >= chunkSize && s.pos != len(tmpl) {
+			bounds = append(bounds, s.pos)
+		}
+	}
+	return append(bounds, len(tmpl)), nil
+}
+
+// newScanner returns a scanner for tmpl with the Expander's options applied
+func (e *Expander) newScanner(tmpl string) scanner {
+	return scanner{
+		tmpl:        tmpl,
+		keepDollars: e.keepDollars,
+		osSyntax:    e.osSyntax,
+		passInvalid: e.passInvalid,
+		onInvalid:   e.onInvalid,
+		noDefaults:  e.noDefaults,
+		constraints: e.constraints != nil,
+		powerShell:  e.powerShell,
+		positional:  e.positional,
+	}
+}
+
+// grow returns buf with capacity for at least n more bytes
+func grow(buf []byte, n int) []byte {
+	if cap(buf)-len(buf) >= n {
+		return buf
+	}
+	grown := make([]byte, len(buf), len(buf)+n)
+	copy(grown, buf)
+	return grown
+}
+
+// value returns the text that the scanner's current variable token expands to
+func (x *expansion) value(s *scanner) string {
+	x.stats.Placeholders++
+	if val, done := x.guard(s); done {
+		return val
+	}
+	x.stats.Lookups++
+	val, ok := x.env.LookupEnv(s.name)
+	if !ok {
+		x.stats.Misses++
+	}
+	if !ok || x.emptyUnset && val == "" {
+		val, ok = x.resolveDefault(s)
+		if !ok {
+			return x.missing(s.name)
+		}
+	}
+	if x.validUTF8 && !utf8.ValidString(val) {
+		x.err = &InvalidUTF8Error{Variable: s.name}
+		return ""
+	}
+	if s.constraint != "" {
+		x.err = x.checkConstraint(s.name, s.constraint, val)
+		if x.err != nil {
+			return ""
+		}
+	}
+	if x.annotate != nil {
+		return x.annotate(s.name, val)
+	}
+	return val
+}
+
+// guard runs the checks that come before the scanner's current variable is looked up. When done is true, the variable
+// isn't looked up and expands to val. That is either a fragment or "" after an error.
+func (x *expansion) guard(s *scanner) (val string, done bool) {
+	if x.err != nil {
+		return "", true
+	}
+	if x.ctx != nil {
+		x.err = x.ctx.Err()
+		if x.err != nil {
+			return "", true
+		}
+	}
+	if x.limits != nil {
+		x.err = x.checkLimits(s)
+		if x.err != nil {
+			return "", true
+		}
+	}
+	if x.denied != nil && x.isDenied(s.name) {
+		x.err = &DeniedVarError{Name: s.name}
+		return "", true
+	}
+	if fragment, ok := x.fragments[s.name]; ok {
+		return x.expandFragment(s.name, fragment), true
+	}
+	return "", false
+}
+
+// resolveDefault returns the value of the scanner's current variable when it is unset. That is the default value from
+// the template, then the one from WithDefaultProvider. ok is false when there is neither and the missing-variable
+// handler should be used instead.
+func (x *expansion) resolveDefault(s *scanner) (_ string, ok bool) {
+	if s.hasDefault {
+		x.stats.Defaults++
+		return s.defaultValue(), true
+	}
+	if x.defaults != nil {
+		if v, found := x.defaults.DefaultValue(s.name); found {
+			x.stats.Defaults++
+			return v, true
+		}
+	}
+	return "", x.missing == nil
+}
+
+// expandFragment returns the expansion of the fragment with the given name
+func (x *expansion) expandFragment(name, fragment string) string {
+	for i, n := range x.fragmentStack {
+		if n == name {
+			cycle := append(append([]string{}, x.fragmentStack[i:]...), name)
+			x.err = &FragmentCycleError{Fragments: cycle}
+			return ""
+		}
+	}
+	x.fragmentStack = append(x.fragmentStack, name)
+	s := x.newScanner(fragment)
+	buf, err := x.expand(&s, nil)
+	x.fragmentStack = x.fragmentStack[:len(x.fragmentStack)-1]
+	if err != nil {
+		if x.err == nil {
+			x.err = err
+		}
+		return ""
+	}
+	return string(buf)
+}
+
+// FragmentCycleError is returned when a fragment references itself
+type FragmentCycleError struct {
+	// Fragments are the names of the fragments in the cycle. The first and last names are the same.
+	Fragments []string
+}
+
+func (e *FragmentCycleError) Error() string {
+	return fmt.Sprintf("fragment cycle: %s", strings.Join(e.Fragments, " -> "))
+}
+
+// checkLimits returns a *LimitError when the scanner's current variable exceeds the Expander's limits
+func (x *expansion) checkLimits(s *scanner) error {
+	l := x.limits
+	switch {
+	case l.MaxPlaceholders > 0 && x.stats.Placeholders > l.MaxPlaceholders:
+		return &LimitError{Limit: "MaxPlaceholders", Max: l.MaxPlaceholders}
+	case l.MaxNameLength > 0 && len(s.name) > l.MaxNameLength:
+		return &LimitError{Limit: "MaxNameLength", Max: l.MaxNameLength}
+	case l.MaxDefaultLength > 0 && len(s.rawDefault) > l.MaxDefaultLength:
+		return &LimitError{Limit: "MaxDefaultLength", Max: l.MaxDefaultLength}
+	}
+	return nil
+}
+
+// LimitError is returned by an Expander WithLimits when a template exceeds one of its Limits.
+type LimitError struct {
+	// Limit is the name of the Limits field that was exceeded
+	Limit string
+
+	// Max is the value of the limit
+	Max int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("template exceeds %s of %d", e.Limit, e.Max)
+}
+
+// isDenied reports whether name is matched by WithDeniedVars
+func (e *Expander) isDenied(name string) bool {
+	if e.denied[name] {
+		return true
+	}
+	for _, pattern := range e.deniedGlobs {
+		// patterns were checked by WithDeniedVars
+		if ok, _ := path.Match(pattern, name); ok { //nolint:errcheck
+			return true
+		}
+	}
+	return false
+}
+
+// DeniedVarError is returned by an Expander WithDeniedVars when a template references a denied variable.
+type DeniedVarError struct {
+	// Name is the name of the denied variable
+	Name string
+}
+
+func (e *DeniedVarError) Error() string {
+	return fmt.Sprintf("variable %s is not allowed", e.Name)
+}
+
+// checkUTF8 returns an *InvalidUTF8Error when the Expander has WithUTF8Validation and out isn't valid UTF-8. offset
+// is the position of out in the output.
+func (x *expansion) checkUTF8(out []byte, offset int) error {
+	if !x.validUTF8 || utf8.Valid(out) {
+		return nil
+	}
+	i := 0
+	for i < len(out) {
+		r, size := utf8.DecodeRune(out[i:])
+		if r == utf8.RuneError && size == 1 {
+			break
+		}
+		i += size
+	}
+	return &InvalidUTF8Error{Offset: offset + i}
+}
+
+// incompleteRuneLen returns the length of the start of a multibyte rune at the end of p that needs more bytes to be
+// complete
+func incompleteRuneLen(p []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(p); i++ {
+		c := p[len(p)-i]
+		if c < utf8.RuneSelf {
+			return 0
+		}
+		if utf8.RuneStart(c) {
+			if utf8.FullRune(p[len(p)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
+
+// InvalidUTF8Error is returned by an Expander WithUTF8Validation when the output wouldn't be valid UTF-8.
+type InvalidUTF8Error struct {
+	// Variable is the name of the variable whose value isn't valid UTF-8. It is empty when the invalid UTF-8 is in the
+	// template text.
+	Variable string
+
+	// Offset is the position of the first invalid byte in the output when Variable is empty
+	Offset int
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	if e.Variable != "" {
+		return fmt.Sprintf("value of %s is not valid UTF-8", e.Variable)
+	}
+	return fmt.Sprintf("invalid UTF-8 at offset %d of output", e.Offset)
+}
+
+// memoEnv is an Environment that remembers the results of lookups in the Environment it wraps. It is safe for
+// concurrent use when the wrapped Environment is.
+type memoEnv struct {
+	env    Environment
+	mu     sync.Mutex
+	values map[string]memoValue
+
+	// lookups and misses count the lookups in env
+	lookups int
+	misses  int
+}
+
+type memoValue struct {
+	val string
+	ok  bool
+}
+
+func newMemoEnv(env Environment) *memoEnv {
+	return &memoEnv{
+		env:    env,
+		values: map[string]memoValue{},
+	}
+}
+
+func (m *memoEnv) LookupEnv(key string) (string, bool) {
+	m.mu.Lock()
+	v, found := m.values[key]
+	m.mu.Unlock()
+	if found {
+		return v.val, v.ok
+	}
+	v.val, v.ok = m.env.LookupEnv(key)
+	m.mu.Lock()
+	m.values[key] = v
+	m.lookups++
+	if !v.ok {
+		m.misses++
+	}
+	m.mu.Unlock()
+	return v.val, v.ok
+}
@@ -0,0 +1,810 @@
+package expando
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithExactSize(t *testing.T) {
+	expander := NewExpander(WithExactSize())
+	for _, td := range expandTests {
+		t.Run(td.in, func(t *testing.T) {
+			result, err := expander.Expand(td.in, expandTestEnv, nil)
+			if td.err != nil {
+				require.Equal(t, err.Error(), td.err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.out, string(result))
+			require.Equal(t, len(result), cap(result))
+		})
+	}
+
+	t.Run("appends to buf", func(t *testing.T) {
+		buf := []byte("prefix:")
+		result, err := expander.Expand(`${HOME}/bin`, expandTestEnv, buf)
+		require.NoError(t, err)
+		require.Equal(t, `prefix:/usr/gopher/bin`, string(result))
+		require.Equal(t, len(result), cap(result))
+	})
+
+	t.Run("looks up each variable once", func(t *testing.T) {
+		lookups := 0
+		env := envFunc(func(key string) (string, bool) {
+			lookups++
+			return expandTestEnv.LookupEnv(key)
+		})
+		result, err := expander.Expand(`${HOME} ${H} ${HOME}`, env, nil)
+		require.NoError(t, err)
+		require.Equal(t, `/usr/gopher (Value of H) /usr/gopher`, string(result))
+		require.Equal(t, 3, lookups)
+	})
+}
+
+func TestExpander_ExpandStream(t *testing.T) {
+	var expander Expander
+	for _, td := range expandTests {
+		t.Run(td.in, func(t *testing.T) {
+			// one byte at a time makes every variable straddle a read
+			var buf bytes.Buffer
+			err := expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(td.in)), expandTestEnv)
+			if td.err != nil {
+				require.Equal(t, err.Error(), td.err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.out, buf.String())
+		})
+	}
+
+	t.Run("larger than a chunk", func(t *testing.T) {
+		tmplLine := "${HOME|home} $$ ${missing|a default value} ${H}\n"
+		wantLine := "/usr/gopher $ a default value (Value of H)\n"
+		count := 3 * streamChunkSize / len(tmplLine)
+		var buf bytes.Buffer
+		err := expander.ExpandStream(&buf, strings.NewReader(strings.Repeat(tmplLine, count)), expandTestEnv)
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat(wantLine, count), buf.String())
+	})
+
+	t.Run("variable larger than a chunk", func(t *testing.T) {
+		defaultValue := strings.Repeat("x", 2*streamChunkSize)
+		var buf bytes.Buffer
+		err := expander.ExpandStream(&buf, strings.NewReader("a${missing|"+defaultValue+"}b"), expandTestEnv)
+		require.NoError(t, err)
+		require.Equal(t, "a"+defaultValue+"b", buf.String())
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := expander.ExpandStream(&buf, iotest.ErrReader(assert.AnError), expandTestEnv)
+		require.Equal(t, assert.AnError, err)
+	})
+
+	t.Run("no progress", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := expander.ExpandStream(&buf, emptyReader{}, expandTestEnv)
+		require.Equal(t, io.ErrNoProgress, err)
+	})
+}
+
+// emptyReader is an io.Reader that never returns data or an error
+type emptyReader struct{}
+
+func (emptyReader) Read([]byte) (int, error) {
+	return 0, nil
+}
+
+func TestWithParallelism(t *testing.T) {
+	expander := NewExpander(WithParallelism(4))
+
+	t.Run("large template", func(t *testing.T) {
+		tmplLine := "${HOME|home} $$ ${missing|a default value} ${H}\n"
+		wantLine := "/usr/gopher $ a default value (Value of H)\n"
+		count := 10 * minParallelChunkSize / len(tmplLine)
+		buf := []byte("prefix:")
+		result, err := expander.Expand(strings.Repeat(tmplLine, count), expandTestEnv, buf)
+		require.NoError(t, err)
+		require.Equal(t, "prefix:"+strings.Repeat(wantLine, count), string(result))
+	})
+
+	t.Run("error in a later chunk", func(t *testing.T) {
+		tmpl := strings.Repeat("${HOME}\n", 2*minParallelChunkSize) + "${1}"
+		_, err := expander.Expand(tmpl, expandTestEnv, nil)
+		require.EqualError(t, err, newInvalidSyntaxError(2, `${1}`, errInvalidStartingCharacter).Error())
+	})
+
+	for _, td := range expandTests {
+		t.Run(td.in, func(t *testing.T) {
+			result, err := expander.Expand(td.in, expandTestEnv, nil)
+			if td.err != nil {
+				require.Equal(t, err.Error(), td.err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.out, string(result))
+		})
+	}
+}
+
+func Test_chunkBounds(t *testing.T) {
+	tmpl := strings.Repeat("${HOME|a default value}", minParallelChunkSize/4)
+	bounds, err := defaultExpander.chunkBounds(tmpl, 4)
+	require.NoError(t, err)
+	require.Len(t, bounds, 5)
+	require.Equal(t, 0, bounds[0])
+	require.Equal(t, len(tmpl), bounds[4])
+	for _, bound := range bounds[1:4] {
+		require.True(t, strings.HasPrefix(tmpl[bound:], "${HOME|"))
+	}
+
+	bounds, err = defaultExpander.chunkBounds(strings.Repeat("x", 3*minParallelChunkSize), 4)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 3 * minParallelChunkSize}, bounds)
+}
+
+func TestWithSizeHint(t *testing.T) {
+	expander := NewExpander(WithSizeHint(100))
+
+	result, err := expander.Expand(`${HOME}`, expandTestEnv, nil)
+	require.NoError(t, err)
+	require.Equal(t, `/usr/gopher`, string(result))
+	require.Equal(t, 100, cap(result))
+
+	result, err = expander.Expand(`${HOME}`, expandTestEnv, []byte("prefix:"))
+	require.NoError(t, err)
+	require.Equal(t, `prefix:/usr/gopher`, string(result))
+	require.Equal(t, 107, cap(result))
+
+	// buf is left alone when it already has enough capacity
+	buf := make([]byte, 0, 200)
+	result, err = expander.Expand(`${HOME}`, expandTestEnv, buf)
+	require.NoError(t, err)
+	require.Equal(t, `/usr/gopher`, string(result))
+	require.Equal(t, 200, cap(result))
+}
+
+func TestWithMemoizedLookups(t *testing.T) {
+	lookups := map[string]int{}
+	env := envFunc(func(key string) (string, bool) {
+		lookups[key]++
+		return expandTestEnv.LookupEnv(key)
+	})
+	tmpl := `${HOME} ${missing|a} ${HOME} ${missing|b} ${H}`
+	want := `/usr/gopher a /usr/gopher b (Value of H)`
+	expander := NewExpander(WithMemoizedLookups())
+
+	result, err := expander.Expand(tmpl, env, nil)
+	require.NoError(t, err)
+	require.Equal(t, want, string(result))
+	require.Equal(t, map[string]int{"HOME": 1, "missing": 1, "H": 1}, lookups)
+
+	// results are only remembered for a single call
+	var buf bytes.Buffer
+	err = expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(tmpl)), env)
+	require.NoError(t, err)
+	require.Equal(t, want, buf.String())
+	require.Equal(t, map[string]int{"HOME": 2, "missing": 2, "H": 2}, lookups)
+}
+
+type metricsFunc func(stats ExpansionStats)
+
+func (fn metricsFunc) ObserveExpansion(stats ExpansionStats) {
+	fn(stats)
+}
+
+func TestWithMetrics(t *testing.T) {
+	var got []ExpansionStats
+	metrics := metricsFunc(func(stats ExpansionStats) {
+		stats.Duration = 0
+		got = append(got, stats)
+	})
+	tmpl := `x ${HOME} ${missing|a} ${HOME} ${missing}`
+	want := ExpansionStats{
+		Placeholders: 4,
+		Lookups:      4,
+		Misses:       2,
+		Defaults:     1,
+		Bytes:        len(`x /usr/gopher a /usr/gopher `),
+	}
+
+	t.Run("Expand", func(t *testing.T) {
+		got = nil
+		expander := NewExpander(WithMetrics(metrics))
+		_, err := expander.Expand(tmpl, expandTestEnv, []byte("prefix"))
+		require.NoError(t, err)
+		_, err = expander.Expand("${", expandTestEnv, nil)
+		require.Error(t, err)
+		require.Len(t, got, 2)
+		require.Equal(t, want, got[0])
+		require.Equal(t, err, got[1].Err)
+	})
+
+	t.Run("WithMemoizedLookups", func(t *testing.T) {
+		got = nil
+		expander := NewExpander(WithMetrics(metrics), WithMemoizedLookups())
+		_, err := expander.Expand(tmpl, expandTestEnv, nil)
+		require.NoError(t, err)
+		memoWant := want
+		memoWant.Lookups = 2
+		memoWant.Misses = 1
+		require.Equal(t, []ExpansionStats{memoWant}, got)
+	})
+
+	t.Run("WithParallelism", func(t *testing.T) {
+		got = nil
+		expander := NewExpander(WithMetrics(metrics), WithParallelism(4))
+		long := strings.Repeat(tmpl, 4*minParallelChunkSize/len(tmpl))
+		_, err := expander.Expand(long, expandTestEnv, nil)
+		require.NoError(t, err)
+		n := len(long) / len(tmpl)
+		require.Equal(t, []ExpansionStats{{
+			Placeholders: n * want.Placeholders,
+			Lookups:      n * want.Lookups,
+			Misses:       n * want.Misses,
+			Defaults:     n * want.Defaults,
+			Bytes:        n * want.Bytes,
+		}}, got)
+	})
+
+	t.Run("ExpandAppendN", func(t *testing.T) {
+		got = nil
+		expander := NewExpander(WithMetrics(metrics))
+		_, err := expander.ExpandAppendN(make([]byte, 0, 100), 100, tmpl, expandTestEnv)
+		require.NoError(t, err)
+		_, err = expander.ExpandAppendN(nil, 0, tmpl, expandTestEnv)
+		require.Error(t, err)
+		require.Len(t, got, 2)
+		require.Equal(t, want, got[0])
+		require.Equal(t, err, got[1].Err)
+	})
+
+	t.Run("ExpandStream", func(t *testing.T) {
+		got = nil
+		expander := NewExpander(WithMetrics(metrics))
+		var buf bytes.Buffer
+		err := expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(tmpl)), expandTestEnv)
+		require.NoError(t, err)
+		require.Equal(t, []ExpansionStats{want}, got)
+	})
+
+	t.Run("ContextWithTemplateID", func(t *testing.T) {
+		got = nil
+		expander := NewExpander(WithMetrics(metrics))
+		ctx := ContextWithTemplateID(context.Background(), "config.yml")
+		var buf bytes.Buffer
+		err := expander.ExpandStreamContext(ctx, &buf, strings.NewReader(tmpl), expandTestEnv)
+		require.NoError(t, err)
+		idWant := want
+		idWant.TemplateID = "config.yml"
+		require.Equal(t, []ExpansionStats{idWant}, got)
+	})
+}
+
+func TestWithAnnotations(t *testing.T) {
+	tmpl := `home=${HOME} x=${missing|x} $$`
+	for _, td := range []struct {
+		name     string
+		annotate func(name, value string) string
+		want     string
+	}{
+		{
+			name:     "AnnotateInline",
+			annotate: AnnotateInline,
+			want:     `home=«HOME=/usr/gopher» x=«missing=x» $`,
+		},
+		{
+			name:     "AnnotateHTMLComment",
+			annotate: AnnotateHTMLComment,
+			want:     `home=<!-- ${HOME} -->/usr/gopher<!-- /${HOME} --> x=<!-- ${missing} -->x<!-- /${missing} --> $`,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			expander := NewExpander(WithAnnotations(td.annotate))
+			result, err := expander.Expand(tmpl, expandTestEnv, nil)
+			require.NoError(t, err)
+			require.Equal(t, td.want, string(result))
+		})
+	}
+}
+
+func TestWithEmptyAsUnset(t *testing.T) {
+	env := MapEnvironment{"EMPTY": "", "SET": "set"}
+	tmpl := `${EMPTY|a} ${SET|b} ${missing|c} [${EMPTY}]`
+
+	result, err := NewExpander().Expand(tmpl, env, nil)
+	require.NoError(t, err)
+	require.Equal(t, ` set c []`, string(result))
+
+	result, err = NewExpander(WithEmptyAsUnset()).Expand(tmpl, env, nil)
+	require.NoError(t, err)
+	require.Equal(t, `a set c []`, string(result))
+}
+
+func TestWithPowerShellSyntax(t *testing.T) {
+	expander := NewExpander(WithPowerShellSyntax())
+	env := MapEnvironment{"Path": `C:\bin`, "ProgramFiles(x86)": `C:\Program Files (x86)`, "HOME": "/usr/gopher"}
+	for _, td := range []struct {
+		in   string
+		want string
+	}{
+		{in: `$env:Path;$Env:Path`, want: `C:\bin;C:\bin`},
+		{in: `${env:ProgramFiles(x86)}\app`, want: `C:\Program Files (x86)\app`},
+		{in: `${HOME} $env:missing. $env $envx $env:`, want: `/usr/gopher . $env $envx $env:`},
+		{in: `$$env:Path`, want: `$env:Path`},
+		{in: `$env:Path`, want: `C:\bin`},
+		{in: `a $en`, want: `a $en`},
+	} {
+		t.Run(td.in, func(t *testing.T) {
+			result, err := expander.Expand(td.in, env, nil)
+			require.NoError(t, err)
+			require.Equal(t, td.want, string(result))
+
+			var buf bytes.Buffer
+			err = expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(td.in)), env)
+			require.NoError(t, err)
+			require.Equal(t, td.want, buf.String())
+		})
+	}
+
+	_, err := expander.Expand(`${env:Path`, env, nil)
+	require.Error(t, err)
+}
+
+func TestWithKeepDoubleDollar(t *testing.T) {
+	expander := NewExpander(WithKeepDoubleDollar())
+	for _, td := range []struct {
+		in   string
+		want string
+	}{
+		{in: `kill $$PID`, want: `kill $$PID`},
+		{in: `$$`, want: `$$`},
+		{in: `$$$`, want: `$$$`},
+		{in: `$$${HOME}`, want: `$$/usr/gopher`},
+		{in: `$${HOME}`, want: `$${HOME}`},
+		{in: `a $$ ${HOME} $$`, want: `a $$ /usr/gopher $$`},
+	} {
+		t.Run(td.in, func(t *testing.T) {
+			result, err := expander.Expand(td.in, expandTestEnv, nil)
+			require.NoError(t, err)
+			require.Equal(t, td.want, string(result))
+
+			var buf bytes.Buffer
+			err = expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(td.in)), expandTestEnv)
+			require.NoError(t, err)
+			require.Equal(t, td.want, buf.String())
+		})
+	}
+}
+
+func TestWithOSSyntax(t *testing.T) {
+	expander := NewExpander(WithOSSyntax())
+	mapping := func(name string) string {
+		val, ok := expandTestEnv.LookupEnv(name)
+		if !ok {
+			return "<" + name + ">"
+		}
+		return val
+	}
+	env := envFunc(func(name string) (string, bool) {
+		return mapping(name), true
+	})
+	for _, tmpl := range []string{
+		`$HOME and ${HOME}`,
+		`$$ $1 ${*} $`,
+		`${} ${HOME|default} $HOME_DIR`,
+		`${HOME`,
+		`$HOME`,
+		`${1`,
+	} {
+		t.Run(tmpl, func(t *testing.T) {
+			want := os.Expand(tmpl, mapping)
+			result, err := expander.Expand(tmpl, env, nil)
+			require.NoError(t, err)
+			require.Equal(t, want, string(result))
+
+			var buf bytes.Buffer
+			err = expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(tmpl)), env)
+			require.NoError(t, err)
+			require.Equal(t, want, buf.String())
+		})
+	}
+}
+
+func TestWithUTF8Validation(t *testing.T) {
+	expander := NewExpander(WithUTF8Validation())
+	env := MapEnvironment{
+		"GOOD": "日本語",
+		"BAD":  "a\xffb",
+	}
+	for _, td := range []struct {
+		in  string
+		err error
+	}{
+		{in: "${GOOD} ${missing|語}"},
+		{in: "日本語 ${BAD|x} ${GOOD}", err: &InvalidUTF8Error{Variable: "BAD"}},
+		{in: "${missing|\xe6}", err: &InvalidUTF8Error{Variable: "missing"}},
+		{in: "${GOOD} \xe6\x97 ${GOOD}", err: &InvalidUTF8Error{Offset: 10}},
+	} {
+		t.Run(td.in, func(t *testing.T) {
+			_, err := expander.Expand(td.in, env, nil)
+			require.Equal(t, td.err, err)
+
+			_, err = NewExpander(WithUTF8Validation(), WithExactSize()).Expand(td.in, env, nil)
+			require.Equal(t, td.err, err)
+
+			_, err = expander.ExpandAppendN(make([]byte, 0, 100), 100, td.in, env)
+			require.Equal(t, td.err, err)
+
+			var buf bytes.Buffer
+			err = expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(td.in)), env)
+			require.Equal(t, td.err, err)
+		})
+	}
+	long := strings.Repeat("${GOOD}", 3*minParallelChunkSize) + "${BAD}"
+	_, err := NewExpander(WithUTF8Validation(), WithParallelism(4)).Expand(long, env, nil)
+	require.Equal(t, &InvalidUTF8Error{Variable: "BAD"}, err)
+
+	// a value that meets its constraint is still checked
+	_, err = NewExpander(WithUTF8Validation(), WithConstraints()).Expand("${BAD~a.*}", env, nil)
+	require.Equal(t, &InvalidUTF8Error{Variable: "BAD"}, err)
+
+	require.EqualError(t, &InvalidUTF8Error{Variable: "BAD"}, "value of BAD is not valid UTF-8")
+	require.EqualError(t, &InvalidUTF8Error{Offset: 10}, "invalid UTF-8 at offset 10 of output")
+}
+
+func Test_incompleteRuneLen(t *testing.T) {
+	for _, td := range []struct {
+		in   string
+		want int
+	}{
+		{in: "", want: 0},
+		{in: "abc", want: 0},
+		{in: "日", want: 0},
+		{in: "a\xe6", want: 1},
+		{in: "a\xe6\x97", want: 2},
+		{in: "\xf0\x9f\x98", want: 3},
+		{in: "\xff", want: 0},
+		{in: "\x97\x97\x97\x97", want: 0},
+	} {
+		require.Equal(t, td.want, incompleteRuneLen([]byte(td.in)), td.in)
+	}
+}
+
+func TestWithPassThroughInvalid(t *testing.T) {
+	var warnings []string
+	expander := NewExpander(WithPassThroughInvalid(func(err error) {
+		warnings = append(warnings, err.Error())
+	}))
+	tmpl := `# ${not valid} ${HOME} ${${HOME}} ${HOME|\x} $${HOME} ${`
+	want := `# ${not valid} /usr/gopher ${/usr/gopher} ${HOME|\x} ${HOME} ${`
+	wantWarnings := []string{
+		`invalid syntax at position 5 of "${not val": invalid character`,
+		`invalid syntax at position 2 of "${${HO": invalid starting character`,
+		`invalid syntax at position 8 of "${HOME|\\x} $": invalid escape sequence`,
+		`invalid syntax at position 2 of "${": unterminated`,
+	}
+
+	for _, e := range []*Expander{
+		expander,
+		NewExpander(WithPassThroughInvalid(nil)),
+	} {
+		warnings = nil
+		result, err := e.Expand(tmpl, expandTestEnv, nil)
+		require.NoError(t, err)
+		require.Equal(t, want, string(result))
+	}
+	require.Empty(t, warnings)
+
+	warnings = nil
+	_, err := expander.Expand(tmpl, expandTestEnv, nil)
+	require.NoError(t, err)
+	require.Equal(t, wantWarnings, warnings)
+
+	warnings = nil
+	var buf bytes.Buffer
+	err = expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(tmpl)), expandTestEnv)
+	require.NoError(t, err)
+	require.Equal(t, want, buf.String())
+	require.Equal(t, wantWarnings, warnings)
+
+	warnings = nil
+	exact := NewExpander(WithExactSize(), WithPassThroughInvalid(func(err error) {
+		warnings = append(warnings, err.Error())
+	}))
+	result, err := exact.Expand(tmpl, expandTestEnv, nil)
+	require.NoError(t, err)
+	require.Equal(t, want, string(result))
+	require.Equal(t, wantWarnings, warnings)
+}
+
+func TestWithDeniedVars(t *testing.T) {
+	lookups := map[string]int{}
+	env := envFunc(func(key string) (string, bool) {
+		lookups[key]++
+		return "value", true
+	})
+	expander := NewExpander(WithDeniedVars("SECRET", "AWS_*"))
+
+	result, err := expander.Expand(`${HOME} ${AWS} ${SECRETS}`, env, nil)
+	require.NoError(t, err)
+	require.Equal(t, `value value value`, string(result))
+
+	for _, td := range []struct {
+		tmpl    string
+		name    string
+		lookups map[string]int
+	}{
+		{tmpl: `${HOME} ${SECRET}`, name: "SECRET", lookups: map[string]int{"HOME": 1}},
+		// nothing is looked up after a denied variable
+		{tmpl: `${AWS_SECRET_ACCESS_KEY|default} ${HOME}`, name: "AWS_SECRET_ACCESS_KEY", lookups: map[string]int{}},
+	} {
+		lookups = map[string]int{}
+		_, err = expander.Expand(td.tmpl, env, nil)
+		require.Equal(t, &DeniedVarError{Name: td.name}, err)
+		require.Equal(t, td.lookups, lookups)
+
+		var buf bytes.Buffer
+		err = expander.ExpandStream(&buf, strings.NewReader(td.tmpl), env)
+		require.Equal(t, &DeniedVarError{Name: td.name}, err)
+	}
+	require.EqualError(t, &DeniedVarError{Name: "SECRET"}, "variable SECRET is not allowed")
+
+	require.PanicsWithValue(t, `expando: invalid denied variable pattern "[": syntax error in pattern`, func() {
+		WithDeniedVars("[")
+	})
+}
+
+func TestWithLimits(t *testing.T) {
+	lookups := 0
+	env := envFunc(func(key string) (string, bool) {
+		lookups++
+		return "", false
+	})
+	expander := NewExpander(WithLimits(Limits{
+		MaxPlaceholders:  3,
+		MaxNameLength:    5,
+		MaxDefaultLength: 4,
+	}))
+	for _, td := range []struct {
+		in      string
+		err     error
+		lookups int
+	}{
+		{in: `${a} ${abcde|1234} ${c}`, lookups: 3},
+		{in: `${a} ${b} ${c} ${d} ${e}`, lookups: 3, err: &LimitError{Limit: "MaxPlaceholders", Max: 3}},
+		{in: `${a} ${abcdef} ${c}`, lookups: 1, err: &LimitError{Limit: "MaxNameLength", Max: 5}},
+		{in: `${a|\}\}\}} ${c}`, lookups: 0, err: &LimitError{Limit: "MaxDefaultLength", Max: 4}},
+	} {
+		t.Run(td.in, func(t *testing.T) {
+			lookups = 0
+			_, err := expander.Expand(td.in, env, nil)
+			require.Equal(t, td.err, err)
+			require.Equal(t, td.lookups, lookups)
+
+			var buf bytes.Buffer
+			err = expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(td.in)), env)
+			require.Equal(t, td.err, err)
+		})
+	}
+
+	t.Run("WithParallelism", func(t *testing.T) {
+		tmpl := strings.Repeat("${HOME}", 3*minParallelChunkSize)
+		n := len(tmpl) / len("${HOME}")
+		lookups := 0
+		env := envFunc(func(key string) (string, bool) {
+			lookups++
+			return expandTestEnv.LookupEnv(key)
+		})
+		limited := NewExpander(WithParallelism(4), WithLimits(Limits{MaxPlaceholders: n - 1}))
+		_, err := limited.Expand(tmpl, env, nil)
+		require.Equal(t, &LimitError{Limit: "MaxPlaceholders", Max: n - 1}, err)
+		// the limit is for the whole template, so no chunk is expanded
+		require.Zero(t, lookups)
+		limited = NewExpander(WithParallelism(4), WithLimits(Limits{MaxPlaceholders: n}))
+		_, err = limited.Expand(tmpl, expandTestEnv, nil)
+		require.NoError(t, err)
+	})
+
+	require.EqualError(t, &LimitError{Limit: "MaxNameLength", Max: 5}, "template exceeds MaxNameLength of 5")
+}
+
+func TestExpander_ExpandRegions(t *testing.T) {
+	var expander Expander
+	result, regions, err := expander.ExpandRegions(`home=${HOME} x=${missing|x} $$ ${H}${missing}`, expandTestEnv, []byte("> "))
+	require.NoError(t, err)
+	require.Equal(t, `> home=/usr/gopher x=x $ (Value of H)`, string(result))
+	require.Equal(t, []Region{
+		{Name: "HOME", Start: 7, End: 18},
+		{Name: "missing", Start: 21, End: 22, Default: true},
+		{Name: "H", Start: 25, End: 37},
+		{Name: "missing", Start: 37, End: 37},
+	}, regions)
+	for _, r := range regions {
+		val, ok := expandTestEnv.LookupEnv(r.Name)
+		if ok {
+			require.Equal(t, val, string(result[r.Start:r.End]))
+		}
+	}
+
+	// defaults from a DefaultProvider are defaults too
+	provider := defaultProviderFunc(func(name string) (string, bool) {
+		return "provided", name == "missing"
+	})
+	result, regions, err = NewExpander(WithDefaultProvider(provider)).ExpandRegions(`${missing}${other}`, expandTestEnv, nil)
+	require.NoError(t, err)
+	require.Equal(t, "provided", string(result))
+	require.Equal(t, []Region{
+		{Name: "missing", Start: 0, End: 8, Default: true},
+		{Name: "other", Start: 8, End: 8},
+	}, regions)
+
+	_, _, err = expander.ExpandRegions(`${`, expandTestEnv, nil)
+	require.Error(t, err)
+}
+
+func TestWithoutDefaults(t *testing.T) {
+	expander := NewExpander(WithoutDefaults())
+	result, err := expander.Expand(`${HOME} $${a|b}`, expandTestEnv, nil)
+	require.NoError(t, err)
+	require.Equal(t, `/usr/gopher ${a|b}`, string(result))
+
+	_, err = expander.Expand(`x ${HOME|default}`, expandTestEnv, nil)
+	require.EqualError(t, err, `invalid syntax at position 6 of "${HOME|def": invalid character`)
+
+	var buf bytes.Buffer
+	err = expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(`x ${HOME|default}`)), expandTestEnv)
+	require.EqualError(t, err, `invalid syntax at position 6 of "${HOME|def": invalid character`)
+
+	passThrough := NewExpander(WithoutDefaults(), WithPassThroughInvalid(nil))
+	result, err = passThrough.Expand(`${HOME|default} ${HOME}`, expandTestEnv, nil)
+	require.NoError(t, err)
+	require.Equal(t, `${HOME|default} /usr/gopher`, string(result))
+}
+
+func TestWithProgress(t *testing.T) {
+	type progress struct {
+		read, written int
+	}
+	var got []progress
+	expander := NewExpander(WithProgress(func(read, written int) {
+		got = append(got, progress{read: read, written: written})
+	}))
+	tmpl := "a${H}" + strings.Repeat("x", streamChunkSize)
+	var buf bytes.Buffer
+	err := expander.ExpandStream(&buf, strings.NewReader(tmpl), expandTestEnv)
+	require.NoError(t, err)
+	require.Equal(t, []progress{
+		{read: streamChunkSize, written: streamChunkSize + 8},
+		{read: len(tmpl), written: buf.Len()},
+		{read: len(tmpl), written: buf.Len()},
+	}, got)
+}
+
+func TestExpander_ExpandContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, expander := range []*Expander{
+		NewExpander(WithExactSize()),
+		NewExpander(WithParallelism(4)),
+	} {
+		_, err := expander.ExpandContext(ctx, strings.Repeat("${HOME}", 3*minParallelChunkSize), expandTestEnv, nil)
+		require.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+func TestExpander_ExpandStreamContext(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewExpander().ExpandStreamContext(context.Background(), &buf, strings.NewReader(`${HOME}`), expandTestEnv)
+	require.NoError(t, err)
+	require.Equal(t, `/usr/gopher`, buf.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	buf.Reset()
+	err = NewExpander().ExpandStreamContext(ctx, &buf, strings.NewReader(`${HOME}`), expandTestEnv)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Empty(t, buf.String())
+}
+
+type defaultProviderFunc func(name string) (string, bool)
+
+func (fn defaultProviderFunc) DefaultValue(name string) (string, bool) {
+	return fn(name)
+}
+
+func TestWithDefaultProvider(t *testing.T) {
+	var calls []string
+	expander := NewExpander(WithDefaultProvider(defaultProviderFunc(func(name string) (string, bool) {
+		calls = append(calls, name)
+		if name == "IP" {
+			return "10.0.0.1", true
+		}
+		return "ignored", false
+	})))
+	result, err := expander.Expand(`${HOME} ${IP} ${missing} ${literal|x} ${empty|}`, expandTestEnv, nil)
+	require.NoError(t, err)
+	require.Equal(t, `/usr/gopher 10.0.0.1  x `, string(result))
+	require.Equal(t, []string{"IP", "missing"}, calls)
+}
+
+func TestWithFragments(t *testing.T) {
+	expander := NewExpander(WithFragments(map[string]string{
+		"db":    "postgres://${this}@${DB_HOST|localhost}/app",
+		"dsns":  "${db} ${db}",
+		"loop":  "x${loop2}",
+		"loop2": "${loop}",
+	}))
+
+	result, err := expander.Expand(`primary=${db} both=${dsns}`, expandTestEnv, nil)
+	require.NoError(t, err)
+	require.Equal(t, `primary=postgres://that@localhost/app both=postgres://that@localhost/app postgres://that@localhost/app`, string(result))
+
+	_, err = expander.Expand(`${loop}`, expandTestEnv, nil)
+	var cycleErr *FragmentCycleError
+	require.ErrorAs(t, err, &cycleErr)
+	require.Equal(t, []string{"loop", "loop2", "loop"}, cycleErr.Fragments)
+	require.EqualError(t, err, "fragment cycle: loop -> loop2 -> loop")
+}
+
+func TestWithMissingMarker(t *testing.T) {
+	expander := NewExpander(WithMissingMarker(MissingMarker), WithEmptyAsUnset())
+	env := MapEnvironment{"HOME": "/usr/gopher", "EMPTY": ""}
+	result, err := expander.Expand(`${HOME} ${FOO} ${BAR|} ${EMPTY}`, env, nil)
+	require.NoError(t, err)
+	require.Equal(t, `/usr/gopher <<MISSING:FOO>>  <<MISSING:EMPTY>>`, string(result))
+
+	expander = NewExpander(
+		WithMissingMarker(MissingMarker),
+		WithDefaultProvider(defaultProviderFunc(func(name string) (string, bool) {
+			return "provided", name == "A"
+		})),
+	)
+	result, err = expander.Expand(`${A} ${B}`, env, nil)
+	require.NoError(t, err)
+	require.Equal(t, `provided <<MISSING:B>>`, string(result))
+}
+
+func TestWithPositionalArgs(t *testing.T) {
+	expander := NewExpander(WithPositionalArgs())
+	env := ArgsEnvironment{"cmd", "a", "b"}
+	for _, td := range []struct {
+		in   string
+		want string
+		err  string
+	}{
+		{in: `${0} ${1} ${2}`, want: `cmd a b`},
+		{in: `${3|none} ${10|} ${01}`, want: `none  a`},
+		{in: `${HOME}`, want: ``},
+		{in: `${1a}`, err: `invalid syntax at position 3 of "${1a}": invalid character`},
+		{in: `${1`, err: `invalid syntax at position 3 of "${1": unterminated`},
+	} {
+		t.Run(td.in, func(t *testing.T) {
+			result, err := expander.Expand(td.in, env, nil)
+			var buf bytes.Buffer
+			streamErr := expander.ExpandStream(&buf, iotest.OneByteReader(strings.NewReader(td.in)), env)
+			if td.err != "" {
+				require.EqualError(t, err, td.err)
+				require.EqualError(t, streamErr, td.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.want, string(result))
+			require.NoError(t, streamErr)
+			require.Equal(t, td.want, buf.String())
+		})
+	}
+
+	_, err := Expand(`${1}`, env, nil)
+	require.Error(t, err)
+	_, err = NewExpander(WithPositionalArgs(), WithoutDefaults()).Expand(`${1|x}`, env, nil)
+	require.Error(t, err)
+}
@@ -4,8 +4,14 @@
 package expando
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"unsafe"
 )
 
 // ExpandEnv is a shortcut for Expand(tmpl, OSEnv, buf)
@@ -38,77 +44,338 @@ func (m MapEnvironment) LookupEnv(key string) (string, bool) {
 // Variable names must start with [a-zA-Z]. Subsequent characters must be [a-zA-Z0-9_].
 // The result is appended to buf
 func Expand(tmpl string, lookupEnv Environment, buf []byte) ([]byte, error) {
-	i := 0
-	dollar := false
-	for j := 0; j < len(tmpl); j++ {
-		switch tmpl[j] {
-		case '$':
-			if !dollar {
-				dollar = true
-				continue
-			}
-			dollar = false
-			buf = append(buf, tmpl[i:j]...)
-			i = j + 1
-		case '{':
-			if !dollar {
-				break
-			}
-			if buf == nil {
-				buf = make([]byte, 0, 2*len(tmpl))
-			}
-			buf = append(buf, tmpl[i:j-1]...)
-			name, defaultValue, w, err := varInfo(tmpl[j+1:])
-			if err != nil {
-				errStringEnd := j + w + 5
-				if errStringEnd > len(tmpl) {
-					errStringEnd = len(tmpl)
-				}
-				err = &invalidSyntaxErr{
-					position: w + 2,
-					value:    tmpl[j-1 : errStringEnd],
-					err:      err,
-				}
-				return nil, err
+	return defaultExpander.Expand(tmpl, lookupEnv, buf)
+}
+
+// ExpandContext is like Expand, but it stops and returns ctx.Err() when ctx is done before tmpl is fully expanded. ctx
+// is checked before each variable is looked up and between segments of literal text, so a canceled expansion of a very
+// large template stops promptly.
+func ExpandContext(ctx context.Context, tmpl string, lookupEnv Environment, buf []byte) ([]byte, error) {
+	return defaultExpander.ExpandContext(ctx, tmpl, lookupEnv, buf)
+}
+
+// ExpandStream is like Expand, but it reads the template from src and writes the expansion to dst, so huge templates
+// are expanded without loading them into memory. Variables that straddle reads from src are handled. Output from the
+// start of the template may already have been written to dst when an error is returned.
+func ExpandStream(dst io.Writer, src io.Reader, lookupEnv Environment) error {
+	return defaultExpander.ExpandStream(dst, src, lookupEnv)
+}
+
+// ExpandAppendN is like Expand, but it never grows dst. The expanded template is appended to dst only if the result
+// fits within max bytes and the capacity of dst. Otherwise, it returns dst unchanged with a *ShortBufferError that
+// reports the size dst needs to be, though the bytes past len(dst) may have been overwritten. This is for programs
+// that preallocate all of their memory.
+func ExpandAppendN(dst []byte, max int, tmpl string, lookupEnv Environment) ([]byte, error) {
+	return defaultExpander.ExpandAppendN(dst, max, tmpl, lookupEnv)
+}
+
+// ExpandString expands tmpl like Expand and returns the result as a string. The output isn't copied: for most templates
+// the bytes Expand writes become the string. Templates that are a single variable, optionally preceded by literal text,
+// like "${PORT}" or "https://${HOST}", are expanded without an intermediate buffer, and when tmpl is just one variable
+// the result is the value from lookupEnv itself.
+func ExpandString(tmpl string, lookupEnv Environment) (string, error) {
+	i := strings.IndexByte(tmpl, '$')
+	if i == -1 {
+		return tmpl, nil
+	}
+	if strings.HasPrefix(tmpl[i:], "${") && tmpl[len(tmpl)-1] == '}' {
+		s := scanner{tmpl: tmpl, pos: i}
+		kind, err := s.next()
+		if err != nil {
+			return "", err
+		}
+		if kind == tokenVar && s.pos == len(tmpl) {
+			x := defaultExpander.newExpansion(lookupEnv)
+			if i == 0 {
+				return x.value(&s), nil
 			}
-			val, ok := lookupEnv.LookupEnv(name)
-			if ok {
-				buf = append(buf, val...)
-			} else {
-				buf = append(buf, defaultValue...)
+			return tmpl[:i] + x.value(&s), nil
+		}
+	}
+	buf, err := Expand(tmpl, lookupEnv, nil)
+	if err != nil {
+		return "", err
+	}
+	// buf was allocated by Expand and nothing else refers to it. It isn't written to or returned after this, so the
+	// string can't change.
+	return *(*string)(unsafe.Pointer(&buf)), nil //nolint:gosec // buf is never modified after the conversion
+}
+
+// tokenKind identifies the kind of token returned by scanner.next
+type tokenKind uint8
+
+const (
+	tokenEOF tokenKind = iota
+	tokenLiteral
+	tokenVar
+)
+
+// scanner breaks a template into literal text and variables
+type scanner struct {
+	tmpl string
+	// pos is the position in tmpl where the next token starts
+	pos int
+	// more means that tmpl is followed by more template text that hasn't been read yet. When more is set, the scan
+	// stops at the start of a trailing variable or "$" that may be completed by the text that follows.
+	more bool
+	// keepDollars means "$$" is literal text instead of an escaped "$"
+	keepDollars bool
+	// osSyntax means variables are parsed like os.Expand instead of expando syntax
+	osSyntax bool
+	// passInvalid means invalid variables are literal text instead of an error. They are reported to onInvalid when
+	// it isn't nil.
+	passInvalid bool
+	onInvalid   func(error)
+	// noDefaults means "|" is an invalid character in a variable instead of the start of a default value
+	noDefaults bool
+	// constraints means "~" after a variable name starts a constraint
+	constraints bool
+	// powerShell means $env:NAME and ${env:NAME} are variables too
+	powerShell bool
+	// positional means variable names can be all digits like ${1}
+	positional bool
+
+	// text is the literal text of the current token. For a tokenVar it is the literal text preceding the variable.
+	text string
+
+	// name, hasDefault, rawDefault, defaultEscaped and constraint describe the current tokenVar
+	name           string
+	hasDefault     bool
+	rawDefault     string
+	defaultEscaped bool
+	constraint     string
+}
+
+// next advances to the next token in the template and returns its kind. It returns tokenEOF when the template has been
+// consumed. Literal text is returned in as few tokens as possible, but "$$" ends a literal token because only one of
+// the dollar signs is part of the output. Literal text immediately before a variable is part of the tokenVar.
+func (s *scanner) next() (tokenKind, error) {
+	tmpl := s.tmpl
+	i := s.pos
+	if i == len(tmpl) {
+		return tokenEOF, nil
+	}
+	j := i
+	for {
+		// jump straight to the next "$" instead of examining every byte of literal text
+		k := strings.IndexByte(tmpl[j:], '$')
+		if k == -1 || j+k+1 == len(tmpl) {
+			if s.more && k != -1 {
+				return s.stop(i, j+k), nil
 			}
-			j += w
-			i = j + 1
-			dollar = false
-		default:
-			dollar = false
+			s.pos = len(tmpl)
+			s.text = tmpl[i:]
+			return tokenLiteral, nil
+		}
+		j += k
+		var kind tokenKind
+		var done bool
+		var err error
+		if s.osSyntax {
+			kind, j, done = s.scanOSVar(i, j)
+		} else {
+			kind, j, done, err = s.scanVar(i, j)
+		}
+		if done {
+			return kind, err
+		}
+	}
+}
+
+// The scan helpers below look at the "$" at tmpl[j] of a token that started at tmpl[i]. When done is true, the token
+// has been set up and next returns kind and err. Otherwise, the "$" is literal text and scanning continues at tmpl[n].
+
+// scanOSVar scans a variable in os.Expand syntax
+func (s *scanner) scanOSVar(i, j int) (kind tokenKind, n int, done bool) {
+	name, w, complete := osVarInfo(s.tmpl[j+1:])
+	if s.more && !complete {
+		return s.stop(i, j), j, true
+	}
+	switch {
+	case name != "":
+		s.setVar(i, j, j+w+1, name)
+		return tokenVar, j, true
+	case w > 0:
+		// like os.Expand, invalid syntax is left out of the output
+		s.pos = j + w + 1
+		s.text = s.tmpl[i:j]
+		return tokenLiteral, j, true
+	}
+	// a "$" that isn't followed by a name is literal text
+	return tokenEOF, j + 1, false
+}
+
+// scanVar scans a variable in expando syntax
+func (s *scanner) scanVar(i, j int) (kind tokenKind, n int, done bool, _ error) {
+	if s.powerShell {
+		kind, done = s.scanPowerShellVar(i, j)
+		if done {
+			return kind, j, true, nil
+		}
+	}
+	switch s.tmpl[j+1] {
+	case '$':
+		if s.keepDollars {
+			return tokenEOF, j + 2, false, nil
+		}
+		s.pos = j + 2
+		s.text = s.tmpl[i : j+1]
+		return tokenLiteral, j, true, nil
+	case '{':
+		return s.scanBracedVar(i, j)
+	}
+	return tokenEOF, j + 1, false, nil
+}
+
+// scanPowerShellVar scans a variable in PowerShell's $env:NAME or ${env:NAME} syntax. done is false when there is no
+// PowerShell variable at tmpl[j], and the "$" should be scanned as expando syntax instead.
+func (s *scanner) scanPowerShellVar(i, j int) (kind tokenKind, done bool) {
+	name, w, complete := powerShellVarInfo(s.tmpl[j+1:])
+	if s.more && !complete {
+		return s.stop(i, j), true
+	}
+	if name == "" {
+		return tokenEOF, false
+	}
+	s.setVar(i, j, j+w+1, name)
+	return tokenVar, true
+}
+
+// scanBracedVar scans a variable like ${name}, ${name|default} or ${name~constraint|default}
+func (s *scanner) scanBracedVar(i, j int) (kind tokenKind, n int, done bool, _ error) {
+	tmpl := s.tmpl
+	name, constraint, defaultValue, escaped, w, err := s.bracedVarInfo(tmpl[j+2:])
+	if err != nil {
+		// wait for the rest of the variable, or enough of the following text to report the error
+		if s.more && (err == errUnterminated || syntaxErrorEnd(j, w) > len(tmpl)) {
+			return s.stop(i, j), j, true, nil
+		}
+		if !s.passInvalid {
+			return tokenEOF, j, true, newSyntaxError(tmpl, j, w, err)
+		}
+		if s.onInvalid != nil {
+			s.onInvalid(newSyntaxError(tmpl, j, w, err))
 		}
+		// the "$" is literal text, and scanning continues after it
+		return tokenEOF, j + 1, false, nil
 	}
-	buf = append(buf, tmpl[i:]...)
-	return buf, nil
+	s.setVar(i, j, j+w+2, name)
+	// the default value starts after the name and constraint
+	end := j + 2 + len(name)
+	if constraint != "" {
+		end += len(constraint) + 1
+	}
+	s.hasDefault = tmpl[end] == '|'
+	s.rawDefault = defaultValue
+	s.defaultEscaped = escaped
+	s.constraint = constraint
+	return tokenVar, j, true, nil
+}
+
+// bracedVarInfo is varInfo for the syntax the scanner is configured for. data is the remainder of a string after "${".
+func (s *scanner) bracedVarInfo(data string) (name, constraint, defaultValue string, escaped bool, n int, err error) {
+	switch {
+	case s.positional && data != "" && '0' <= data[0] && data[0] <= '9':
+		name, defaultValue, escaped, n, err = positionalVarInfo(data, s.noDefaults)
+	case s.noDefaults:
+		name, n, err = readVarNameNoDefault(data)
+	case s.constraints:
+		name, constraint, defaultValue, escaped, n, err = constrainedVarInfo(data)
+	default:
+		name, defaultValue, escaped, n, err = varInfo(data)
+	}
+	return name, constraint, defaultValue, escaped, n, err
+}
+
+// setVar makes the current token a tokenVar for name without a default value or constraint. The variable starts at
+// tmpl[j], and the next token starts at tmpl[pos].
+func (s *scanner) setVar(i, j, pos int, name string) {
+	s.pos = pos
+	s.text = s.tmpl[i:j]
+	s.name = name
+	s.hasDefault = false
+	s.rawDefault = ""
+	s.defaultEscaped = false
+	s.constraint = ""
+}
+
+// defaultValue returns the default value of the current tokenVar
+func (s *scanner) defaultValue() string {
+	if s.defaultEscaped {
+		return unescapeDefault(s.rawDefault)
+	}
+	return s.rawDefault
+}
+
+// stop ends the scan at end. If there is literal text between i and end, it is returned as a tokenLiteral first.
+func (s *scanner) stop(i, end int) tokenKind {
+	s.pos = end
+	if end == i {
+		return tokenEOF
+	}
+	s.text = s.tmpl[i:end]
+	return tokenLiteral
 }
 
 // varInfo returns information about a variable to be expanded.
 // data is the remainder of a string after "${"
 // name is the variable name
-// defaultValue is the default value (the portion after a | pipe) or "" if no pipe is found
+// defaultValue is the default value (the portion after a | pipe) as it appears in data or "" if no pipe is found
+// escaped reports whether defaultValue contains escape sequences that need to be removed with unescapeDefault
 // n is the position in data after "}", or in case of an error, it's the position where the syntax becomes invalid
-func varInfo(data string) (name, defaultValue string, n int, _ error) {
+func varInfo(data string) (name, defaultValue string, escaped bool, n int, _ error) {
 	var err error
 	var nameLen int
 	name, nameLen, err = readVarName(data)
 	if err != nil {
-		return "", "", nameLen, err
+		return "", "", false, nameLen, err
 	}
 	if data[nameLen-1] == '}' {
-		return name, "", nameLen, nil
+		return name, "", false, nameLen, nil
 	}
 	var valLen int
-	defaultValue, valLen, err = readDefaultValue(data[nameLen:])
+	defaultValue, valLen, escaped, err = skipDefaultValue(data[nameLen:])
 	if err != nil {
-		return "", "", nameLen + valLen, err
+		return "", "", false, nameLen + valLen, err
 	}
-	return name, defaultValue, nameLen + valLen, nil
+	return name, defaultValue, escaped, nameLen + valLen, nil
+}
+
+// positionalVarInfo is varInfo for a positional variable like ${1}. data starts with a digit. Default values are
+// allowed unless noDefaults is set.
+func positionalVarInfo(data string, noDefaults bool) (name, defaultValue string, escaped bool, n int, _ error) {
+	i := 0
+	for i < len(data) && '0' <= data[i] && data[i] <= '9' {
+		i++
+	}
+	if i == len(data) {
+		return "", "", false, i, errUnterminated
+	}
+	switch {
+	case data[i] == '}':
+		return data[:i], "", false, i + 1, nil
+	case data[i] == '|' && !noDefaults:
+		defaultValue, valLen, escaped, err := skipDefaultValue(data[i+1:])
+		if err != nil {
+			return "", "", false, i + 1 + valLen, err
+		}
+		return data[:i], defaultValue, escaped, i + 1 + valLen, nil
+	}
+	return "", "", false, i, errInvalidCharacter
+}
+
+// ArgsEnvironment is an Environment of positional arguments for templates expanded by an Expander
+// WithPositionalArgs. ${0} is the first element, so ArgsEnvironment(os.Args) has the program name as ${0} and the
+// first argument as ${1} like the shell. Variables that aren't an index of the slice are unset.
+type ArgsEnvironment []string
+
+// LookupEnv implements Environment.LookupEnv
+func (a ArgsEnvironment) LookupEnv(key string) (string, bool) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || i >= len(a) {
+		return "", false
+	}
+	return a[i], true
 }
 
 // readVarName returns the variable name at the start of data. data should always be a string starting with the
@@ -136,68 +403,205 @@ func readVarName(data string) (string, int, error) {
 	return "", len(data), errUnterminated
 }
 
-// readDefaultValue returns a default value. If we are working with text that contains "${foo|bar}", then "|bar}" will
-// be passed to readDefaultValue. It also returns the number of bytes read.
-func readDefaultValue(data string) (string, int, error) {
-	var i int
-
-	// iterate until we find either an escape or a terminator
-	// this lets us avoid making buffer if there is no escape character before the terminator
-	var hasEscape bool
+// constrainedVarInfo is varInfo for templates with constraints like ${name~constraint|default}. The constraint is
+// returned as it is written in data, with "\|" and "\}" escaped.
+func constrainedVarInfo(data string) (name, constraint, defaultValue string, escaped bool, n int, _ error) {
+	name, nameLen, err := readVarName(data)
+	if err != errInvalidCharacter || data[nameLen] != '~' {
+		// there is no constraint
+		name, defaultValue, escaped, n, err = varInfo(data)
+		return name, "", defaultValue, escaped, n, err
+	}
+	name = data[:nameLen]
+	i := nameLen + 1
 	for ; i < len(data); i++ {
-		switch data[i] {
-		case '\\':
-			hasEscape = true
-		case '}':
-			return data[:i], i + 1, nil
+		c := data[i]
+		if c == '\\' && i+1 < len(data) && (data[i+1] == '|' || data[i+1] == '}') {
+			i++
+			continue
 		}
-		if hasEscape {
+		if c == '|' || c == '}' {
 			break
 		}
 	}
-
-	// it's unterminated if we made it to the end without finding a "}"
 	if i == len(data) {
-		return "", i, errUnterminated
+		return "", "", "", false, i, errUnterminated
+	}
+	if i == nameLen+1 {
+		return "", "", "", false, i, errEmptyConstraint
+	}
+	constraint = data[nameLen+1 : i]
+	if data[i] == '}' {
+		return name, constraint, "", false, i + 1, nil
 	}
+	var valLen int
+	defaultValue, valLen, escaped, err = skipDefaultValue(data[i+1:])
+	if err != nil {
+		return "", "", "", false, i + 1 + valLen, err
+	}
+	return name, constraint, defaultValue, escaped, i + 1 + valLen, nil
+}
 
-	buf := make([]byte, i, len(data))
-	copy(buf, data[:i])
-	var escaped, foundClose bool
-iter:
-	for ; i < len(data); i++ {
+// readVarNameNoDefault is readVarName for templates without default values. A "|" after the name is an invalid
+// character.
+func readVarNameNoDefault(data string) (string, int, error) {
+	name, n, err := readVarName(data)
+	if err == nil && data[n-1] == '|' {
+		return "", n - 1, errInvalidCharacter
+	}
+	return name, n, err
+}
+
+// skipDefaultValue validates the default value at the start of data and returns it without removing escape
+// sequences. If we are working with text that contains "${foo|bar}", then "bar}" will be passed to skipDefaultValue.
+// It also returns the number of bytes read and whether the value contains any escape sequences. Skipping doesn't
+// allocate, so the cost of unescaping is only paid when a default value is actually used.
+func skipDefaultValue(data string) (string, int, bool, error) {
+	escaped := false
+	for i := 0; i < len(data); i++ {
 		switch data[i] {
+		case '}':
+			return data[:i], i + 1, escaped, nil
 		case '\\':
-			if escaped {
-				buf = append(buf, data[i])
+			i++
+			if i == len(data) {
+				return "", i, false, errUnterminated
 			}
-			escaped = !escaped
-			continue
-		case '}':
-			if !escaped {
-				foundClose = true
-				break iter
+			if data[i] != '\\' && data[i] != '}' {
+				return "", i, false, errInvalidEscape
 			}
+			escaped = true
 		}
-		if escaped && data[i] != '}' {
-			return "", i, errInvalidEscape
+	}
+	return "", len(data), false, errUnterminated
+}
+
+// unescapeDefault removes escape sequences from a default value returned by skipDefaultValue
+func unescapeDefault(value string) string {
+	var sb strings.Builder
+	sb.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+		}
+		sb.WriteByte(value[i])
+	}
+	return sb.String()
+}
+
+// osVarInfo is varInfo for os.Expand syntax. data is the remainder of a string after "$". It returns the variable name
+// and the number of bytes of data that are part of the variable. An empty name with n > 0 means invalid syntax. An
+// empty name with n == 0 means the "$" is literal text. complete is false when more text after data could change the
+// result.
+func osVarInfo(data string) (name string, n int, complete bool) {
+	switch {
+	case data[0] == '{':
+		if len(data) > 2 && isShellSpecialVar(data[1]) && data[2] == '}' {
+			return data[1:2], 3, true
+		}
+		for i := 1; i < len(data); i++ {
+			if data[i] == '}' {
+				if i == 1 {
+					return "", 2, true
+				}
+				return data[1:i], i + 1, true
+			}
 		}
-		buf = append(buf, data[i])
-		escaped = false
+		return "", 1, false
+	case isShellSpecialVar(data[0]):
+		return data[:1], 1, true
 	}
-	if !foundClose {
-		return "", i, errUnterminated
+	i := 0
+	for i < len(data) && validNameChar(data[i]) {
+		i++
+	}
+	return data[:i], i, i < len(data)
+}
+
+// powerShellVarInfo is like osVarInfo for PowerShell's $env:NAME and ${env:NAME} syntax. The "env:" prefix isn't case
+// sensitive. An empty name means data doesn't start with a PowerShell variable.
+func powerShellVarInfo(data string) (name string, n int, complete bool) {
+	const prefix = "env:"
+	braced := data[0] == '{'
+	start := 0
+	if braced {
+		start = 1
+	}
+	rest := data[start:]
+	if len(rest) < len(prefix) {
+		// more text could complete the prefix
+		return "", 0, !strings.EqualFold(rest, prefix[:len(rest)])
+	}
+	if !strings.EqualFold(rest[:len(prefix)], prefix) {
+		return "", 0, true
+	}
+	start += len(prefix)
+	if braced {
+		end := strings.IndexByte(data[start:], '}')
+		if end == -1 {
+			return "", 0, false
+		}
+		return data[start : start+end], start + end + 1, true
 	}
-	return string(buf), i + 1, nil
+	i := start
+	for i < len(data) && validNameChar(data[i]) {
+		i++
+	}
+	return data[start:i], i, i < len(data)
+}
+
+// isShellSpecialVar reports whether c is a single character variable name in os.Expand syntax like "$*" or "$1"
+func isShellSpecialVar(c uint8) bool {
+	switch c {
+	case '*', '#', '$', '@', '!', '?', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+	return false
+}
+
+// ShortBufferError is returned by ExpandAppendN when the expanded template doesn't fit in the destination.
+type ShortBufferError struct {
+	// Size is the length the destination needs to hold the expanded template
+	Size int
+}
+
+func (e *ShortBufferError) Error() string {
+	return fmt.Sprintf("short buffer: need %d bytes", e.Size)
+}
+
+// Is reports whether target is io.ErrShortBuffer
+func (e *ShortBufferError) Is(target error) bool {
+	return target == io.ErrShortBuffer
+}
+
+// newSyntaxError returns the error for an invalid variable that starts at tmpl[start]. w is the position after "${"
+// where varInfo found the syntax to be invalid. The error is the only allocation, and formatting is deferred until
+// Error is called.
+func newSyntaxError(tmpl string, start, w int, err error) error {
+	end := syntaxErrorEnd(start, w)
+	if end > len(tmpl) {
+		end = len(tmpl)
+	}
+	return &SyntaxError{
+		position: w + 2,
+		value:    tmpl[start:end],
+		err:      err,
+	}
+}
+
+// syntaxErrorEnd returns where the text quoted by newSyntaxError ends. It includes a few bytes past the invalid syntax.
+func syntaxErrorEnd(start, w int) int {
+	return start + w + 6
 }
 
-type invalidSyntaxErr struct {
+// SyntaxError is returned for templates with invalid syntax
+type SyntaxError struct {
 	position int
 	value    string
 	err      error
 }
 
-func (e *invalidSyntaxErr) Error() string {
+func (e *SyntaxError) Error() string {
 	return fmt.Sprintf(
 		"invalid syntax at position %d of %q: %v",
 		e.position, e.value, e.err,
@@ -205,11 +609,12 @@ func (e *invalidSyntaxErr) Error() string {
 }
 
 var (
-	errInvalidCharacter         = fmt.Errorf("invalid character")
-	errInvalidStartingCharacter = fmt.Errorf("invalid starting character")
-	errUnterminated             = fmt.Errorf("unterminated")
-	errEmptyString              = fmt.Errorf("empty string")
-	errInvalidEscape            = fmt.Errorf("invalid escape sequence")
+	errInvalidCharacter         = errors.New("invalid character")
+	errInvalidStartingCharacter = errors.New("invalid starting character")
+	errUnterminated             = errors.New("unterminated")
+	errEmptyString              = errors.New("empty string")
+	errInvalidEscape            = errors.New("invalid escape sequence")
+	errEmptyConstraint          = errors.New("empty constraint")
 )
 
 func validNameFirstChar(c uint8) bool {
@@ -1,7 +1,11 @@
 package expando
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -45,6 +49,7 @@ func Test_varInfo(t *testing.T) {
 		input        string
 		name         string
 		defaultValue string
+		escaped      bool
 		length       int
 		wantErr      bool
 	}{
@@ -57,9 +62,10 @@ func Test_varInfo(t *testing.T) {
 		{input: `foo|hello|world}`, name: `foo`, defaultValue: `hello|world`, length: 16},
 		{input: `w\orld}`, wantErr: true, length: 1},
 		{input: `foo|}`, name: `foo`, length: 5},
+		{input: `foo|a\}b}`, name: `foo`, defaultValue: `a\}b`, escaped: true, length: 9},
 	} {
 		t.Run(td.input, func(t *testing.T) {
-			name, defaultValue, length, err := varInfo(td.input)
+			name, defaultValue, escaped, length, err := varInfo(td.input)
 			if td.wantErr {
 				require.True(t, err != nil)
 			} else {
@@ -67,36 +73,56 @@ func Test_varInfo(t *testing.T) {
 			}
 			require.Equal(t, td.name, name)
 			require.Equal(t, td.defaultValue, defaultValue)
+			require.Equal(t, td.escaped, escaped)
 			require.Equal(t, td.length, length)
 		})
 	}
 }
 
-func Test_readDefaultValue(t *testing.T) {
+func Test_skipDefaultValue(t *testing.T) {
 	for _, td := range []struct {
-		input  string
-		output string
-		length int
-		err    error
+		input   string
+		output  string
+		length  int
+		escaped bool
+		err     error
 	}{
 		{input: "", err: errUnterminated},
 		{input: `asdf`, length: 4, err: errUnterminated},
 		{input: `asdf}`, length: 5, output: `asdf`},
-		{input: `as\}f}`, length: 6, output: `as}f`},
-		{input: `as\}}f}`, length: 5, output: `as}`},
-		{input: `as\\\}}f}`, length: 7, output: `as\}`},
-		{input: `\\\}}f}`, length: 5, output: `\}`},
-		{input: `\\\}}f}`, length: 5, output: `\}`},
+		{input: `as\}f}`, length: 6, output: `as\}f`, escaped: true},
+		{input: `as\}}f}`, length: 5, output: `as\}`, escaped: true},
+		{input: `as\\\}}f}`, length: 7, output: `as\\\}`, escaped: true},
+		{input: `\\\}}f}`, length: 5, output: `\\\}`, escaped: true},
 		{input: `world|foo}`, length: 10, output: `world|foo`},
 		{input: `w\orld}`, length: 2, err: errInvalidEscape},
 		{input: `world`, length: 5, err: errUnterminated},
 		{input: `w\\orld`, length: 7, err: errUnterminated},
+		{input: `world\`, length: 6, err: errUnterminated},
 	} {
 		t.Run(td.input, func(t *testing.T) {
-			output, length, err := readDefaultValue(td.input)
+			output, length, escaped, err := skipDefaultValue(td.input)
 			require.Equal(t, td.err, err)
 			require.Equal(t, td.length, length)
 			require.Equal(t, td.output, output)
+			require.Equal(t, td.escaped, escaped)
+		})
+	}
+}
+
+func Test_unescapeDefault(t *testing.T) {
+	for _, td := range []struct {
+		input  string
+		output string
+	}{
+		{input: ``, output: ``},
+		{input: `asdf`, output: `asdf`},
+		{input: `as\}f`, output: `as}f`},
+		{input: `as\\\}`, output: `as\}`},
+		{input: `\\\}`, output: `\}`},
+	} {
+		t.Run(td.input, func(t *testing.T) {
+			require.Equal(t, td.output, unescapeDefault(td.input))
 		})
 	}
 }
@@ -128,46 +154,53 @@ func Test_readVarName(t *testing.T) {
 	}
 }
 
+var expandTestEnv = MapEnvironment{
+	`HOME`:   `/usr/gopher`,
+	`H`:      `(Value of H)`,
+	`home_1`: `/usr/foo`,
+	`this`:   `that`,
+}
+
+var expandTests = []struct {
+	in  string
+	out string
+	err error
+}{
+	{},
+	{in: `$*`, out: `$*`},
+	{in: `{${HOME}}`, out: `{/usr/gopher}`},
+	{in: `$${this}`, out: `${this}`},
+	{in: `$$${this}`, out: `$that`},
+	{in: `$$$$`, out: `$$`},
+	{in: `a$$$`, out: `a$$`},
+	{in: `${HOME|unterminated`, err: newInvalidSyntaxError(19, `${HOME|unterminated`, errUnterminated)},
+	{in: `$1`, out: `$1`},
+	{in: `${1}`, err: newInvalidSyntaxError(2, `${1}`, errInvalidStartingCharacter)},
+	{in: `now is the time`, out: `now is the time`},
+	{in: `${home_1}`, out: `/usr/foo`},
+	{in: `${H}OME`, out: `(Value of H)OME`},
+	{in: `a${H}run`, out: `a(Value of H)run`},
+	{in: `start$+middle$^end$`, out: `start$+middle$^end$`},
+	{in: `$`, out: `$`},
+	{in: `$}`, out: `$}`},
+	{in: `${`, err: newInvalidSyntaxError(2, `${`, errUnterminated)},
+	{in: `${asdf`, err: newInvalidSyntaxError(6, `${asdf`, errUnterminated)},
+	{in: `a$df${asdf`, err: newInvalidSyntaxError(6, `${asdf`, errUnterminated)},
+	{in: `${}`, err: newInvalidSyntaxError(2, `${}`, errEmptyString)},
+	{in: `abc${}`, err: newInvalidSyntaxError(2, `${}`, errEmptyString)},
+	{in: `abc${hello|world|foo}`, out: `abcworld|foo`},
+	{in: `abc${hello|`, err: newInvalidSyntaxError(8, `${hello|`, errUnterminated)},
+	{in: `abc${hello|w\orld}`, err: newInvalidSyntaxError(10, `${hello|w\orld`, errInvalidEscape)},
+	{in: `abc${hello\world}`, err: newInvalidSyntaxError(7, `${hello\wor`, errInvalidCharacter)},
+	{in: `${hello|\\world}`, out: `\world`},
+	{in: `${HOME|a\}b}`, out: `/usr/gopher`},
+	{in: `${HOME|w\orld}`, err: newInvalidSyntaxError(9, `${HOME|w\orld`, errInvalidEscape)},
+}
+
 func TestExpand(t *testing.T) {
-	lookupEnv := MapEnvironment{
-		`HOME`:   `/usr/gopher`,
-		`H`:      `(Value of H)`,
-		`home_1`: `/usr/foo`,
-		`this`:   `that`,
-	}
-	for _, td := range []struct {
-		in  string
-		out string
-		err error
-	}{
-		{},
-		{in: `$*`, out: `$*`},
-		{in: `{${HOME}}`, out: `{/usr/gopher}`},
-		{in: `$${this}`, out: `${this}`},
-		{in: `$$${this}`, out: `$that`},
-		{in: `${HOME|unterminated`, err: newInvalidSyntaxError(19, `${HOME|unterminated`, errUnterminated)},
-		{in: `$1`, out: `$1`},
-		{in: `${1}`, err: newInvalidSyntaxError(2, `${1}`, errInvalidStartingCharacter)},
-		{in: `now is the time`, out: `now is the time`},
-		{in: `${home_1}`, out: `/usr/foo`},
-		{in: `${H}OME`, out: `(Value of H)OME`},
-		{in: `a${H}run`, out: `a(Value of H)run`},
-		{in: `start$+middle$^end$`, out: `start$+middle$^end$`},
-		{in: `$`, out: `$`},
-		{in: `$}`, out: `$}`},
-		{in: `${`, err: newInvalidSyntaxError(2, `${`, errUnterminated)},
-		{in: `${asdf`, err: newInvalidSyntaxError(6, `${asdf`, errUnterminated)},
-		{in: `a$df${asdf`, err: newInvalidSyntaxError(6, `${asdf`, errUnterminated)},
-		{in: `${}`, err: newInvalidSyntaxError(2, `${}`, errEmptyString)},
-		{in: `abc${}`, err: newInvalidSyntaxError(2, `${}`, errEmptyString)},
-		{in: `abc${hello|world|foo}`, out: `abcworld|foo`},
-		{in: `abc${hello|`, err: newInvalidSyntaxError(8, `${hello|`, errUnterminated)},
-		{in: `abc${hello|w\orld}`, err: newInvalidSyntaxError(10, `${hello|w\orld`, errInvalidEscape)},
-		{in: `abc${hello\world}`, err: newInvalidSyntaxError(7, `${hello\wor`, errInvalidCharacter)},
-		{in: `${hello|\\world}`, out: `\world`},
-	} {
+	for _, td := range expandTests {
 		t.Run(td.in, func(t *testing.T) {
-			result, err := Expand(td.in, lookupEnv, nil)
+			result, err := Expand(td.in, expandTestEnv, nil)
 			if td.err != nil {
 				require.Equal(t, err.Error(), td.err.Error())
 			} else {
@@ -178,10 +211,154 @@ func TestExpand(t *testing.T) {
 	}
 }
 
-func newInvalidSyntaxError(position int, value string, err error) *invalidSyntaxErr {
-	return &invalidSyntaxErr{
+func newInvalidSyntaxError(position int, value string, err error) *SyntaxError {
+	return &SyntaxError{
 		position: position,
 		value:    value,
 		err:      err,
 	}
 }
+
+func TestExpand_allocations(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+	var err error
+
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, err = Expand(`a ${HOME} b ${missing|x|y} $$ c ${H|a\}b}`, expandTestEnv, buf[:0])
+	})
+	require.NoError(t, err)
+	require.Zero(t, allocs)
+
+	// the error itself is the only allocation
+	allocs = testing.AllocsPerRun(100, func() {
+		_, err = Expand(`a ${HOME} b ${missing|x\y}`, expandTestEnv, buf[:0])
+	})
+	require.Error(t, err)
+	require.Equal(t, float64(1), allocs)
+}
+
+func TestExpandString(t *testing.T) {
+	for _, td := range expandTests {
+		t.Run(td.in, func(t *testing.T) {
+			result, err := ExpandString(td.in, expandTestEnv)
+			if td.err != nil {
+				require.Equal(t, err.Error(), td.err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, td.out, result)
+		})
+	}
+
+	for _, td := range []struct {
+		in     string
+		out    string
+		allocs float64
+	}{
+		{in: `${HOME}`, out: `/usr/gopher`},
+		{in: `${missing|a default}`, out: `a default`},
+		{in: `no variables`, out: `no variables`},
+		{in: `home=${HOME}`, out: `home=/usr/gopher`, allocs: 1},
+		{in: `${HOME}/bin`, out: `/usr/gopher/bin`, allocs: 1},
+	} {
+		t.Run(td.in, func(t *testing.T) {
+			var result string
+			var err error
+			allocs := testing.AllocsPerRun(100, func() {
+				result, err = ExpandString(td.in, expandTestEnv)
+			})
+			require.NoError(t, err)
+			require.Equal(t, td.out, result)
+			require.Equal(t, td.allocs, allocs)
+		})
+	}
+}
+
+func TestExpandAppendN(t *testing.T) {
+	tmpl := `${HOME}/bin`
+	want := `prefix:/usr/gopher/bin`
+
+	t.Run("fits", func(t *testing.T) {
+		dst := make([]byte, 0, len(want))
+		dst = append(dst, "prefix:"...)
+		var result []byte
+		var err error
+		allocs := testing.AllocsPerRun(100, func() {
+			result, err = ExpandAppendN(dst, len(want), tmpl, expandTestEnv)
+		})
+		require.NoError(t, err)
+		require.Zero(t, allocs)
+		require.Equal(t, want, string(result))
+		require.Equal(t, &dst[0], &result[0])
+	})
+
+	t.Run("max is too small", func(t *testing.T) {
+		dst := make([]byte, 0, 100)
+		dst = append(dst, "prefix:"...)
+		result, err := ExpandAppendN(dst, len(want)-1, tmpl, expandTestEnv)
+		require.Equal(t, &ShortBufferError{Size: len(want)}, err)
+		require.ErrorIs(t, err, io.ErrShortBuffer)
+		require.EqualError(t, err, "short buffer: need 22 bytes")
+		require.Equal(t, "prefix:", string(result))
+	})
+
+	t.Run("capacity is too small", func(t *testing.T) {
+		dst := make([]byte, 0, len(want)-1)
+		dst = append(dst, "prefix:"...)
+		result, err := ExpandAppendN(dst, 100, tmpl, expandTestEnv)
+		require.Equal(t, &ShortBufferError{Size: len(want)}, err)
+		require.Equal(t, "prefix:", string(result))
+		require.Equal(t, len(want)-1, cap(result))
+	})
+
+	for _, td := range expandTests {
+		t.Run(td.in, func(t *testing.T) {
+			result, err := ExpandAppendN(make([]byte, 0, 100), 100, td.in, expandTestEnv)
+			if td.err != nil {
+				require.Equal(t, err.Error(), td.err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.out, string(result))
+		})
+	}
+}
+
+func TestExpandStream(t *testing.T) {
+	// put the variables at every offset around the end of the first read from src
+	for offset := -24; offset <= 0; offset++ {
+		tmpl := strings.Repeat("x", streamChunkSize+offset) + `${HOME} $$ ${missing|a\}b}`
+		var buf bytes.Buffer
+		err := ExpandStream(&buf, strings.NewReader(tmpl), expandTestEnv)
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat("x", streamChunkSize+offset)+`/usr/gopher $ a}b`, buf.String())
+	}
+
+	var buf bytes.Buffer
+	err := ExpandStream(&buf, strings.NewReader(`ok ${`), expandTestEnv)
+	require.Error(t, err)
+}
+
+func TestExpandContext(t *testing.T) {
+	result, err := ExpandContext(context.Background(), `${HOME} ${missing|x}`, expandTestEnv, nil)
+	require.NoError(t, err)
+	require.Equal(t, `/usr/gopher x`, string(result))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ExpandContext(ctx, `${HOME}`, expandTestEnv, nil)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// nothing is looked up after ctx is canceled
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var lookups []string
+	env := envFunc(func(key string) (string, bool) {
+		lookups = append(lookups, key)
+		cancel()
+		return "", false
+	})
+	_, err = ExpandContext(ctx, `${a} ${b} ${c}`, env, nil)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []string{"a"}, lookups)
+}
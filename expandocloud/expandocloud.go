@@ -0,0 +1,240 @@
+// Package expandocloud has expando Environments backed by cloud instance metadata services, so templates rendered on
+// cloud hosts can reference facts about the machine like ${INSTANCE_ID} and ${REGION}.
+//
+// Each Environment has the same variable names where the clouds have an equivalent. Values are fetched the first time
+// they are looked up and cached for the life of the Environment, and so are values the instance doesn't have. A value
+// that can't be fetched is unset, so use Prefetch to find out about errors before expanding templates. Failures are
+// cached for 30 seconds, and when the metadata service can't be reached at all every lookup fails without making a
+// request for that long, so using an Environment off-cloud doesn't make every lookup wait for a timeout.
+package expandocloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment is an expando.Environment with values from an instance metadata service. It is safe for concurrent use.
+type Environment struct {
+	provider *provider
+	client   *http.Client
+	endpoint string
+
+	// errorTTL is how long failures are cached
+	errorTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*cachedValue
+	// serviceErr is the error from the last request that couldn't reach the metadata service. Lookups fail with it
+	// until serviceErrExpires.
+	serviceErr        error
+	serviceErrExpires time.Time
+
+	// tokenMu guards token and tokenExpires, the EC2 IMDSv2 session token
+	tokenMu      sync.Mutex
+	token        string
+	tokenExpires time.Time
+}
+
+// cachedValue is a value that is being fetched or was fetched. Its fields are set before done is closed.
+type cachedValue struct {
+	done  chan struct{}
+	value string
+	ok    bool
+	err   error
+	// expires is when a failed fetch is retried
+	expires time.Time
+}
+
+// Option configures an Environment
+type Option func(*Environment)
+
+// WithHTTPClient sets the client for requests to the metadata service. The default client has a 2 second timeout. A
+// nil client is ignored.
+func WithHTTPClient(client *http.Client) Option {
+	return func(e *Environment) {
+		if client != nil {
+			e.client = client
+		}
+	}
+}
+
+// WithEndpoint sets the base URL of the metadata service, for proxies and tests
+func WithEndpoint(endpoint string) Option {
+	return func(e *Environment) {
+		e.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+func newEnvironment(p *provider, options []Option) *Environment {
+	e := &Environment{
+		provider: p,
+		client:   &http.Client{Timeout: 2 * time.Second},
+		endpoint: p.endpoint,
+		errorTTL: 30 * time.Second,
+		cache:    map[string]*cachedValue{},
+	}
+	for _, o := range options {
+		o(e)
+	}
+	return e
+}
+
+// Keys returns the variable names the Environment has values for, sorted
+func (e *Environment) Keys() []string {
+	keys := make([]string, 0, len(e.provider.paths))
+	for key := range e.provider.paths {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LookupEnv implements expando.Environment. Names that aren't in Keys, values the instance doesn't have and values
+// that can't be fetched are unset.
+func (e *Environment) LookupEnv(key string) (string, bool) {
+	v, err := e.lookup(context.Background(), key)
+	if err != nil {
+		return "", false
+	}
+	return v.value, v.ok
+}
+
+// Prefetch fetches every value in Keys so later lookups don't make requests. It returns the first error. Values the
+// instance doesn't have aren't errors.
+func (e *Environment) Prefetch(ctx context.Context) error {
+	for _, key := range e.Keys() {
+		_, err := e.lookup(ctx, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errNotFound is returned by fetch when the metadata service doesn't have a value
+var errNotFound = errors.New("not found")
+
+// lookup returns the value for key, fetching it when it isn't cached. Concurrent lookups of a key that is being
+// fetched wait for that fetch instead of making their own requests.
+func (e *Environment) lookup(ctx context.Context, key string) (*cachedValue, error) {
+	path, ok := e.provider.paths[key]
+	if !ok {
+		return &cachedValue{}, nil
+	}
+	e.mu.Lock()
+	now := time.Now()
+	if e.serviceErr != nil && now.Before(e.serviceErrExpires) {
+		err := e.serviceErr
+		e.mu.Unlock()
+		return nil, fmt.Errorf("fetching %s: %w", key, err)
+	}
+	v, ok := e.cache[key]
+	if ok && v.err != nil && !now.Before(v.expires) {
+		ok = false
+	}
+	if !ok {
+		v = &cachedValue{done: make(chan struct{})}
+		e.cache[key] = v
+		e.mu.Unlock()
+		e.fetch(ctx, key, path, v)
+	} else {
+		e.mu.Unlock()
+		// wait for the fetch another lookup started, which may already be done
+		select {
+		case <-v.done:
+		case <-ctx.Done():
+			select {
+			case <-v.done:
+			default:
+				return nil, ctx.Err()
+			}
+		}
+	}
+	if v.err != nil {
+		return nil, v.err
+	}
+	return v, nil
+}
+
+// fetch fetches the value for key into v and closes v.done
+func (e *Environment) fetch(ctx context.Context, key, path string, v *cachedValue) {
+	val, err := e.provider.fetch(ctx, e, path)
+	e.mu.Lock()
+	defer func() {
+		e.mu.Unlock()
+		close(v.done)
+	}()
+	switch {
+	case errors.Is(err, errNotFound):
+	case err != nil:
+		v.err = fmt.Errorf("fetching %s: %w", key, err)
+		v.expires = time.Now().Add(e.errorTTL)
+		if ctx.Err() != nil && e.cache[key] == v {
+			// the caller gave up, so the next lookup shouldn't have to wait for errorTTL
+			delete(e.cache, key)
+		}
+	default:
+		if transform := e.provider.transforms[key]; transform != nil {
+			val = transform(val)
+		}
+		v.value = val
+		v.ok = true
+	}
+}
+
+// provider describes a cloud's metadata service
+type provider struct {
+	endpoint string
+	// paths maps variable names to metadata paths
+	paths map[string]string
+	// transforms convert fetched values for variables that need it
+	transforms map[string]func(string) string
+	// fetch returns the value for a metadata path. It is called without the Environment's mutex held and may be called
+	// concurrently.
+	fetch func(ctx context.Context, e *Environment, path string) (string, error)
+}
+
+// get makes a request to the metadata service and returns the response body. A 404 response is errNotFound.
+func (e *Environment) get(ctx context.Context, method, url string, header http.Header) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			e.mu.Lock()
+			e.serviceErr = err
+			e.serviceErrExpires = time.Now().Add(e.errorTTL)
+			e.mu.Unlock()
+		}
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck // nothing to do about a failed close
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", errNotFound
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// lastSegment returns the part of s after the last "/"
+func lastSegment(s string) string {
+	return s[strings.LastIndexByte(s, '/')+1:]
+}
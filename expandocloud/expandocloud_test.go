@@ -0,0 +1,227 @@
+package expandocloud
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/expando"
+)
+
+// metadataServer serves values by path and counts requests. Requests without the header are rejected.
+func metadataServer(t *testing.T, header, headerValue string, values map[string]string) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get(header) != headerValue {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		val, ok := values[r.Method+" "+r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(val + "\n")) //nolint:errcheck // test server
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestNewEC2(t *testing.T) {
+	var tokenRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			atomic.AddInt32(&tokenRequests, 1)
+			require.Equal(t, "21600", r.Header.Get("X-Aws-Ec2-Metadata-Token-Ttl-Seconds"))
+			w.Write([]byte("token")) //nolint:errcheck // test server
+		case r.Header.Get("X-Aws-Ec2-Metadata-Token") != "token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/instance-id":
+			w.Write([]byte("i-123")) //nolint:errcheck // test server
+		case r.URL.Path == "/latest/meta-data/placement/region":
+			w.Write([]byte("us-east-1")) //nolint:errcheck // test server
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	env := NewEC2(WithEndpoint(server.URL + "/"))
+	result, err := expando.Expand(`${INSTANCE_ID} ${REGION} ${INSTANCE_TYPE|unknown} ${OTHER|x}`, env, nil)
+	require.NoError(t, err)
+	require.Equal(t, "i-123 us-east-1 unknown x", string(result))
+	require.Equal(t, int32(1), atomic.LoadInt32(&tokenRequests))
+}
+
+func TestNewGCE(t *testing.T) {
+	server, requests := metadataServer(t, "Metadata-Flavor", "Google", map[string]string{
+		"GET /computeMetadata/v1/instance/zone":         "projects/123/zones/us-central1-a",
+		"GET /computeMetadata/v1/instance/machine-type": "projects/123/machineTypes/e2-small",
+		"GET /computeMetadata/v1/project/project-id":    "my-project",
+	})
+	env := NewGCE(WithEndpoint(server.URL))
+	result, err := expando.Expand(`${REGION} ${AVAILABILITY_ZONE} ${INSTANCE_TYPE} ${PROJECT_ID} ${PROJECT_ID}`, env, nil)
+	require.NoError(t, err)
+	require.Equal(t, "us-central1 us-central1-a e2-small my-project my-project", string(result))
+	// PROJECT_ID is cached
+	require.Equal(t, int32(4), atomic.LoadInt32(requests))
+}
+
+func TestNewAzure(t *testing.T) {
+	server, _ := metadataServer(t, "Metadata", "true", map[string]string{
+		"GET /metadata/instance/compute/location?api-version=2021-02-01&format=text": "westus2",
+	})
+	env := NewAzure(WithEndpoint(server.URL))
+	val, ok := env.LookupEnv("REGION")
+	require.True(t, ok)
+	require.Equal(t, "westus2", val)
+	_, ok = env.LookupEnv("HOSTNAME")
+	require.False(t, ok)
+	require.NoError(t, env.Prefetch(context.Background()))
+}
+
+func TestEnvironment_Prefetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+	env := NewGCE(WithEndpoint(server.URL))
+	err := env.Prefetch(context.Background())
+	require.EqualError(t, err, "fetching AVAILABILITY_ZONE: GET "+server.URL+"/computeMetadata/v1/instance/zone: 500 Internal Server Error")
+	_, ok := env.LookupEnv("REGION")
+	require.False(t, ok)
+}
+
+func TestEnvironment_LookupEnv(t *testing.T) {
+	t.Run("failures are cached", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		t.Cleanup(server.Close)
+		env := NewGCE(WithEndpoint(server.URL))
+		for i := 0; i < 3; i++ {
+			_, ok := env.LookupEnv("REGION")
+			require.False(t, ok)
+		}
+		require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+		// failures are retried after errorTTL
+		env = NewGCE(WithEndpoint(server.URL))
+		env.errorTTL = 0
+		env.LookupEnv("REGION")
+		env.LookupEnv("REGION")
+		require.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("unreachable service", func(t *testing.T) {
+		var requests int32
+		client := &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			atomic.AddInt32(&requests, 1)
+			return nil, errors.New("no route to host")
+		})}
+		env := NewEC2(WithHTTPClient(client))
+		for _, key := range env.Keys() {
+			_, ok := env.LookupEnv(key)
+			require.False(t, ok)
+		}
+		require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+		err := env.Prefetch(context.Background())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no route to host")
+		require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("concurrent lookups", func(t *testing.T) {
+		release := make(chan struct{})
+		var hostnameRequests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/computeMetadata/v1/instance/hostname":
+				atomic.AddInt32(&hostnameRequests, 1)
+				<-release
+				w.Write([]byte("host")) //nolint:errcheck // test server
+			case "/computeMetadata/v1/instance/id":
+				w.Write([]byte("123")) //nolint:errcheck // test server
+			}
+		}))
+		t.Cleanup(server.Close)
+		env := NewGCE(WithEndpoint(server.URL))
+		val, ok := env.LookupEnv("INSTANCE_ID")
+		require.True(t, ok)
+		require.Equal(t, "123", val)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				val, ok := env.LookupEnv("HOSTNAME")
+				require.True(t, ok)
+				require.Equal(t, "host", val)
+			}()
+		}
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt32(&hostnameRequests) == 1
+		}, time.Second, time.Millisecond)
+
+		// cache hits don't wait for the fetch in progress
+		val, ok = env.LookupEnv("INSTANCE_ID")
+		require.True(t, ok)
+		require.Equal(t, "123", val)
+
+		close(release)
+		wg.Wait()
+		require.Equal(t, int32(1), atomic.LoadInt32(&hostnameRequests))
+	})
+	t.Run("context canceled after the fetch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			cancel()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("us-central1-a")),
+				Request:    req,
+			}, nil
+		})}
+		env := NewGCE(WithHTTPClient(client))
+		v, err := env.lookup(ctx, "AVAILABILITY_ZONE")
+		require.NoError(t, err)
+		require.Equal(t, "us-central1-a", v.value)
+
+		// a cached value is returned with a canceled context
+		v, err = env.lookup(ctx, "AVAILABILITY_ZONE")
+		require.NoError(t, err)
+		require.Equal(t, "us-central1-a", v.value)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	env := NewGCE(WithHTTPClient(nil))
+	require.NotNil(t, env.client)
+}
+
+func TestEnvironment_Keys(t *testing.T) {
+	require.Equal(t, []string{
+		"AVAILABILITY_ZONE", "HOSTNAME", "INSTANCE_ID", "INSTANCE_TYPE", "LOCAL_IPV4", "REGION",
+	}, NewEC2().Keys())
+}
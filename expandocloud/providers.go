@@ -0,0 +1,115 @@
+package expandocloud
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ec2TokenTTL is how long EC2 IMDSv2 session tokens last
+const ec2TokenTTL = 6 * time.Hour
+
+var ec2Provider = &provider{
+	endpoint: "http://169.254.169.254",
+	paths: map[string]string{
+		"INSTANCE_ID":       "instance-id",
+		"INSTANCE_TYPE":     "instance-type",
+		"REGION":            "placement/region",
+		"AVAILABILITY_ZONE": "placement/availability-zone",
+		"HOSTNAME":          "local-hostname",
+		"LOCAL_IPV4":        "local-ipv4",
+	},
+	fetch: func(ctx context.Context, e *Environment, path string) (string, error) {
+		token, err := e.ec2Token(ctx)
+		if err != nil {
+			return "", err
+		}
+		return e.get(ctx, http.MethodGet, e.endpoint+"/latest/meta-data/"+path, http.Header{
+			"X-Aws-Ec2-Metadata-Token": {token},
+		})
+	},
+}
+
+// ec2Token returns the IMDSv2 session token, requesting a new one when it is missing or expired
+func (e *Environment) ec2Token(ctx context.Context) (string, error) {
+	e.tokenMu.Lock()
+	defer e.tokenMu.Unlock()
+	if e.token == "" || time.Now().After(e.tokenExpires) {
+		token, err := e.get(ctx, http.MethodPut, e.endpoint+"/latest/api/token", http.Header{
+			"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"21600"},
+		})
+		if err != nil {
+			return "", err
+		}
+		e.token = token
+		// renew early so a token doesn't expire between the check and the request
+		e.tokenExpires = time.Now().Add(ec2TokenTTL - time.Minute)
+	}
+	return e.token, nil
+}
+
+// NewEC2 returns an Environment for the EC2 instance metadata service using IMDSv2 session tokens. Its variables are
+// INSTANCE_ID, INSTANCE_TYPE, REGION, AVAILABILITY_ZONE, HOSTNAME and LOCAL_IPV4.
+func NewEC2(options ...Option) *Environment {
+	return newEnvironment(ec2Provider, options)
+}
+
+var gceProvider = &provider{
+	endpoint: "http://metadata.google.internal",
+	paths: map[string]string{
+		"INSTANCE_ID":       "instance/id",
+		"INSTANCE_TYPE":     "instance/machine-type",
+		"REGION":            "instance/zone",
+		"AVAILABILITY_ZONE": "instance/zone",
+		"HOSTNAME":          "instance/hostname",
+		"LOCAL_IPV4":        "instance/network-interfaces/0/ip",
+		"PROJECT_ID":        "project/project-id",
+	},
+	transforms: map[string]func(string) string{
+		// machine types and zones are like projects/123/zones/us-central1-a
+		"INSTANCE_TYPE":     lastSegment,
+		"AVAILABILITY_ZONE": lastSegment,
+		"REGION": func(s string) string {
+			zone := lastSegment(s)
+			if i := strings.LastIndexByte(zone, '-'); i != -1 {
+				return zone[:i]
+			}
+			return zone
+		},
+	},
+	fetch: func(ctx context.Context, e *Environment, path string) (string, error) {
+		return e.get(ctx, http.MethodGet, e.endpoint+"/computeMetadata/v1/"+path, http.Header{
+			"Metadata-Flavor": {"Google"},
+		})
+	},
+}
+
+// NewGCE returns an Environment for the Google Compute Engine metadata server. Its variables are INSTANCE_ID,
+// INSTANCE_TYPE, REGION, AVAILABILITY_ZONE, HOSTNAME, LOCAL_IPV4 and PROJECT_ID.
+func NewGCE(options ...Option) *Environment {
+	return newEnvironment(gceProvider, options)
+}
+
+var azureProvider = &provider{
+	endpoint: "http://169.254.169.254",
+	paths: map[string]string{
+		"INSTANCE_ID":       "compute/vmId",
+		"INSTANCE_TYPE":     "compute/vmSize",
+		"REGION":            "compute/location",
+		"AVAILABILITY_ZONE": "compute/zone",
+		"HOSTNAME":          "compute/name",
+		"LOCAL_IPV4":        "network/interface/0/ipv4/ipAddress/0/privateIpAddress",
+		"SUBSCRIPTION_ID":   "compute/subscriptionId",
+	},
+	fetch: func(ctx context.Context, e *Environment, path string) (string, error) {
+		return e.get(ctx, http.MethodGet, e.endpoint+"/metadata/instance/"+path+"?api-version=2021-02-01&format=text",
+			http.Header{"Metadata": {"true"}})
+	},
+}
+
+// NewAzure returns an Environment for the Azure Instance Metadata Service. Its variables are INSTANCE_ID,
+// INSTANCE_TYPE, REGION, AVAILABILITY_ZONE, HOSTNAME, LOCAL_IPV4 and SUBSCRIPTION_ID.
+func NewAzure(options ...Option) *Environment {
+	return newEnvironment(azureProvider, options)
+}
@@ -0,0 +1,176 @@
+// Package expandohttp provides an http.Handler that renders expando templates, so a platform can offer template
+// rendering as a service.
+package expandohttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/willabides/expando"
+)
+
+// DefaultMaxRequestSize is the request size limit when Handler.MaxRequestSize is zero
+const DefaultMaxRequestSize = 1 << 20
+
+// Request is the JSON body of a request to a Handler
+type Request struct {
+	// Template is the template to expand
+	Template string `json:"template"`
+	// Environment is the name of one of the Handler's Environments to expand the template against
+	Environment string `json:"environment,omitempty"`
+	// Env has variables to expand the template against. Its values take precedence over Environment. It is only
+	// allowed when Handler.AllowRequestEnv is set.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// ErrorResponse is the JSON body of a response to a request that failed
+type ErrorResponse struct {
+	// Kind is one of "request", "syntax", "limit", "denied", "constraint", "utf8", "fragment", "canceled" or "internal"
+	Kind  string `json:"kind"`
+	Error string `json:"error"`
+	// Variable is the variable that caused the error for kinds "denied", "constraint" and "utf8"
+	Variable string `json:"variable,omitempty"`
+}
+
+// Handler is an http.Handler that expands the template posted in a Request. The expanded template is written to the
+// response as text/plain. Failed requests get an ErrorResponse with status 400 for invalid requests, 405 for methods
+// other than POST, 413 for requests larger than MaxRequestSize, 422 for templates that fail to expand, 503 when the
+// request's context is done before the template is expanded and 500 for other errors.
+type Handler struct {
+	// Expander expands the templates. Configure it with expando.WithLimits to limit the work a request can do. A nil
+	// Expander behaves like expando.Expand.
+	Expander *expando.Expander
+	// Environments are the environments requests can expand templates against, by name. A Request with an
+	// Environment that isn't here is rejected.
+	Environments map[string]expando.Environment
+	// AllowRequestEnv allows requests to send their own variables in Request.Env
+	AllowRequestEnv bool
+	// MaxRequestSize is the maximum size of a request body in bytes. Zero means DefaultMaxRequestSize.
+	MaxRequestSize int64
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, &ErrorResponse{Kind: "request", Error: "method not allowed"})
+		return
+	}
+	maxSize := h.MaxRequestSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxRequestSize
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ErrorResponse{Kind: "request", Error: err.Error()})
+		return
+	}
+	if int64(len(body)) > maxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, &ErrorResponse{Kind: "request", Error: "request too large"})
+		return
+	}
+	var req Request
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ErrorResponse{Kind: "request", Error: err.Error()})
+		return
+	}
+	env, errResp := h.environment(&req)
+	if errResp != nil {
+		writeError(w, http.StatusBadRequest, errResp)
+		return
+	}
+	expander := h.Expander
+	if expander == nil {
+		expander = &expando.Expander{}
+	}
+	result, err := expander.ExpandContext(r.Context(), req.Template, env, nil)
+	if err != nil {
+		status, resp := expandError(err)
+		writeError(w, status, resp)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(result) //nolint:errcheck // there is nothing to do about a failed write
+}
+
+// environment returns the environment for req
+func (h *Handler) environment(req *Request) (expando.Environment, *ErrorResponse) {
+	var named expando.Environment
+	if req.Environment != "" {
+		var ok bool
+		named, ok = h.Environments[req.Environment]
+		if !ok {
+			return nil, &ErrorResponse{Kind: "request", Error: "unknown environment: " + req.Environment}
+		}
+	}
+	if req.Env != nil && !h.AllowRequestEnv {
+		return nil, &ErrorResponse{Kind: "request", Error: "env is not allowed"}
+	}
+	switch {
+	case req.Env == nil && named == nil:
+		return expando.MapEnvironment{}, nil
+	case req.Env == nil:
+		return named, nil
+	case named == nil:
+		return expando.MapEnvironment(req.Env), nil
+	}
+	return layeredEnv{expando.MapEnvironment(req.Env), named}, nil
+}
+
+// layeredEnv looks up variables in each of its environments in order
+type layeredEnv []expando.Environment
+
+func (l layeredEnv) LookupEnv(key string) (string, bool) {
+	for _, env := range l {
+		val, ok := env.LookupEnv(key)
+		if ok {
+			return val, true
+		}
+	}
+	return "", false
+}
+
+// expandError returns the status and ErrorResponse for an error from expanding a template
+func expandError(err error) (int, *ErrorResponse) {
+	resp := &ErrorResponse{Error: err.Error()}
+	var limitErr *expando.LimitError
+	var deniedErr *expando.DeniedVarError
+	var constraintErr *expando.ConstraintError
+	var utf8Err *expando.InvalidUTF8Error
+	var cycleErr *expando.FragmentCycleError
+	var syntaxErr *expando.SyntaxError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		resp.Kind = "canceled"
+		return http.StatusServiceUnavailable, resp
+	case errors.As(err, &limitErr):
+		resp.Kind = "limit"
+	case errors.As(err, &deniedErr):
+		resp.Kind = "denied"
+		resp.Variable = deniedErr.Name
+	case errors.As(err, &constraintErr):
+		resp.Kind = "constraint"
+		resp.Variable = constraintErr.Name
+	case errors.As(err, &utf8Err):
+		resp.Kind = "utf8"
+		resp.Variable = utf8Err.Variable
+	case errors.As(err, &cycleErr):
+		resp.Kind = "fragment"
+	case errors.As(err, &syntaxErr):
+		resp.Kind = "syntax"
+	default:
+		resp.Kind = "internal"
+		return http.StatusInternalServerError, resp
+	}
+	return http.StatusUnprocessableEntity, resp
+}
+
+func writeError(w http.ResponseWriter, status int, resp *ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp) //nolint:errcheck // there is nothing to do about a failed write
+}
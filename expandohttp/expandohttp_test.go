@@ -0,0 +1,131 @@
+package expandohttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/expando"
+)
+
+func TestHandler(t *testing.T) {
+	handler := &Handler{
+		Expander: expando.NewExpander(
+			expando.WithDeniedVars("SECRET_*"),
+			expando.WithLimits(expando.Limits{MaxPlaceholders: 3}),
+		),
+		Environments: map[string]expando.Environment{
+			"prod": expando.MapEnvironment{"HOST": "prod.example.com", "PORT": "443"},
+		},
+		AllowRequestEnv: true,
+		MaxRequestSize:  100,
+	}
+
+	for _, td := range []struct {
+		name   string
+		method string
+		body   string
+		status int
+		want   string
+	}{
+		{
+			name:   "environment",
+			body:   `{"template": "${HOST}:${PORT}", "environment": "prod"}`,
+			status: 200,
+			want:   "prod.example.com:443",
+		},
+		{
+			name:   "request env overrides environment",
+			body:   `{"template": "${HOST}:${PORT}", "environment": "prod", "env": {"PORT": "8443"}}`,
+			status: 200,
+			want:   "prod.example.com:8443",
+		},
+		{
+			name:   "no environment",
+			body:   `{"template": "${HOST|localhost}"}`,
+			status: 200,
+			want:   "localhost",
+		},
+		{
+			name:   "unknown environment",
+			body:   `{"template": "", "environment": "dev"}`,
+			status: 400,
+			want:   `{"kind":"request","error":"unknown environment: dev"}`,
+		},
+		{
+			name:   "syntax",
+			body:   `{"template": "${0}"}`,
+			status: 422,
+			want:   `{"kind":"syntax","error":"invalid syntax at position 2 of \"${0}\": invalid starting character"}`,
+		},
+		{
+			name:   "denied",
+			body:   `{"template": "${SECRET_KEY}"}`,
+			status: 422,
+			want:   `{"kind":"denied","error":"variable SECRET_KEY is not allowed","variable":"SECRET_KEY"}`,
+		},
+		{
+			name:   "limit",
+			body:   `{"template": "${a}${a}${a}${a}"}`,
+			status: 422,
+			want:   `{"kind":"limit","error":"template exceeds MaxPlaceholders of 3"}`,
+		},
+		{
+			name:   "too large",
+			body:   `{"template": "` + strings.Repeat("x", 100) + `"}`,
+			status: 413,
+			want:   `{"kind":"request","error":"request too large"}`,
+		},
+		{
+			name:   "invalid json",
+			body:   `{`,
+			status: 400,
+			want:   `{"kind":"request","error":"unexpected end of JSON input"}`,
+		},
+		{
+			name:   "method",
+			method: http.MethodGet,
+			status: 405,
+			want:   `{"kind":"request","error":"method not allowed"}`,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			method := td.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(method, "/", strings.NewReader(td.body)))
+			require.Equal(t, td.status, rec.Code)
+			require.Equal(t, td.want, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"template": "${HOST}"}`)).WithContext(ctx)
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, 503, rec.Code)
+		require.Equal(t, `{"kind":"canceled","error":"context canceled"}`, strings.TrimSpace(rec.Body.String()))
+	})
+
+	t.Run("request env not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h := &Handler{}
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"template": "", "env": {}}`)))
+		require.Equal(t, 400, rec.Code)
+		require.Equal(t, `{"kind":"request","error":"env is not allowed"}`, strings.TrimSpace(rec.Body.String()))
+	})
+}
+
+func Test_expandError(t *testing.T) {
+	status, resp := expandError(errors.New("lookup failed"))
+	require.Equal(t, 500, status)
+	require.Equal(t, &ErrorResponse{Kind: "internal", Error: "lookup failed"}, resp)
+}
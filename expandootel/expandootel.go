@@ -0,0 +1,54 @@
+// Package expandootel traces the lookups of an expando.Expander with OpenTelemetry, so slow renders backed by remote
+// Environments like Vault or SSM show up in the caller's traces.
+package expandootel
+
+import (
+	"context"
+
+	"github.com/willabides/expando"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/willabides/expando/expandootel"
+
+// Attribute keys set on lookup spans. Values are never recorded because they are often secrets.
+const (
+	VariableKey = attribute.Key("expando.variable")
+	FoundKey    = attribute.Key("expando.found")
+)
+
+// Tracer is an expando.Tracer that records each lookup as a span named "expando.lookup" with the variable name in
+// VariableKey and whether it was found in FoundKey. Spans are only started when the context passed to
+// ExpandContext or ExpandStreamContext has a span, so expansions outside of a trace aren't traced.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ expando.Tracer = &Tracer{}
+
+// New returns a Tracer that starts spans with tp. When tp is nil it uses the global TracerProvider.
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{
+		tracer: tp.Tracer(instrumentationName),
+	}
+}
+
+// StartLookup implements expando.Tracer
+func (t *Tracer) StartLookup(ctx context.Context, name string) (context.Context, func(found bool)) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, func(bool) {}
+	}
+	ctx, span := t.tracer.Start(ctx, "expando.lookup",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(VariableKey.String(name)),
+	)
+	return ctx, func(found bool) {
+		span.SetAttributes(FoundKey.Bool(found))
+		span.End()
+	}
+}
@@ -0,0 +1,59 @@
+package expandootel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/expando"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	expander := expando.NewExpander(expando.WithTracer(New(tp)))
+	env := expando.MapEnvironment{"FOO": "secret"}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "render")
+	result, err := expander.ExpandContext(ctx, "${FOO} ${BAR|bar}", env, nil)
+	parent.End()
+	require.NoError(t, err)
+	require.Equal(t, "secret bar", string(result))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	type lookup struct {
+		name  string
+		attrs []attribute.KeyValue
+	}
+	var got []lookup
+	for _, span := range spans[:2] {
+		require.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		got = append(got, lookup{name: span.Name(), attrs: span.Attributes()})
+	}
+	require.Equal(t, []lookup{
+		{name: "expando.lookup", attrs: []attribute.KeyValue{VariableKey.String("FOO"), FoundKey.Bool(true)}},
+		{name: "expando.lookup", attrs: []attribute.KeyValue{VariableKey.String("BAR"), FoundKey.Bool(false)}},
+	}, got)
+	require.Equal(t, "render", spans[2].Name())
+}
+
+func TestTracer_noParent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	expander := expando.NewExpander(expando.WithTracer(New(tp)))
+	result, err := expander.ExpandContext(context.Background(), "${FOO}", expando.MapEnvironment{"FOO": "foo"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(result))
+	require.Empty(t, recorder.Ended())
+}
+
+func TestNew_nil(t *testing.T) {
+	ctx, done := New(nil).StartLookup(context.Background(), "FOO")
+	done(true)
+	require.False(t, trace.SpanContextFromContext(ctx).IsValid())
+}
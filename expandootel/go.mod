@@ -0,0 +1,24 @@
+module github.com/willabides/expando/expandootel
+
+go 1.17
+
+require (
+	github.com/stretchr/testify v1.7.1
+	github.com/willabides/expando v0.0.0
+	go.opentelemetry.io/otel v1.10.0
+	go.opentelemetry.io/otel/sdk v1.10.0
+	go.opentelemetry.io/otel/trace v1.10.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.0.0-20210423185535-09eb48e85fd7 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// expando has no tagged release yet, so this module builds against the expando in the parent directory. Require the
+// first tagged release above and remove this replace once there is one.
+replace github.com/willabides/expando => ../
@@ -0,0 +1,81 @@
+// Package expandoprom reports statistics from an expando.Expander to Prometheus.
+package expandoprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/willabides/expando"
+)
+
+// Metrics is an expando.Metrics that counts expansions with Prometheus counters. It is a prometheus.Collector, so it
+// needs to be registered before its counters are exported.
+type Metrics struct {
+	templates    prometheus.Counter
+	errors       prometheus.Counter
+	placeholders prometheus.Counter
+	lookups      prometheus.Counter
+	misses       prometheus.Counter
+	defaults     prometheus.Counter
+	bytes        prometheus.Counter
+}
+
+var _ expando.Metrics = &Metrics{}
+
+// New returns Metrics with counters named like "<namespace>_expando_templates_expanded_total". namespace may be empty.
+func New(namespace string) *Metrics {
+	counter := func(name, help string) prometheus.Counter {
+		return prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "expando",
+			Name:      name,
+			Help:      help,
+		})
+	}
+	return &Metrics{
+		templates:    counter("templates_expanded_total", "Number of templates expanded."),
+		errors:       counter("errors_total", "Number of expansions that returned an error."),
+		placeholders: counter("placeholders_resolved_total", "Number of variables expanded."),
+		lookups:      counter("lookups_total", "Number of variables looked up in an Environment."),
+		misses:       counter("lookup_misses_total", "Number of lookups that found no value."),
+		defaults:     counter("defaults_used_total", "Number of variables replaced with their default value."),
+		bytes:        counter("bytes_written_total", "Number of bytes of expanded output."),
+	}
+}
+
+// ObserveExpansion implements expando.Metrics
+func (m *Metrics) ObserveExpansion(stats expando.ExpansionStats) {
+	m.templates.Inc()
+	if stats.Err != nil {
+		m.errors.Inc()
+	}
+	m.placeholders.Add(float64(stats.Placeholders))
+	m.lookups.Add(float64(stats.Lookups))
+	m.misses.Add(float64(stats.Misses))
+	m.defaults.Add(float64(stats.Defaults))
+	m.bytes.Add(float64(stats.Bytes))
+}
+
+// Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.counters() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.counters() {
+		c.Collect(ch)
+	}
+}
+
+func (m *Metrics) counters() []prometheus.Counter {
+	return []prometheus.Counter{
+		m.templates,
+		m.errors,
+		m.placeholders,
+		m.lookups,
+		m.misses,
+		m.defaults,
+		m.bytes,
+	}
+}
@@ -0,0 +1,49 @@
+package expandoprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/expando"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := New("test")
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(metrics))
+	expander := expando.NewExpander(expando.WithMetrics(metrics))
+	env := expando.MapEnvironment{"FOO": "foo"}
+
+	_, err := expander.Expand("${FOO} ${BAR|bar}", env, nil)
+	require.NoError(t, err)
+	_, err = expander.Expand("${", env, nil)
+	require.Error(t, err)
+
+	want := `
+# HELP test_expando_bytes_written_total Number of bytes of expanded output.
+# TYPE test_expando_bytes_written_total counter
+test_expando_bytes_written_total 7
+# HELP test_expando_defaults_used_total Number of variables replaced with their default value.
+# TYPE test_expando_defaults_used_total counter
+test_expando_defaults_used_total 1
+# HELP test_expando_errors_total Number of expansions that returned an error.
+# TYPE test_expando_errors_total counter
+test_expando_errors_total 1
+# HELP test_expando_lookup_misses_total Number of lookups that found no value.
+# TYPE test_expando_lookup_misses_total counter
+test_expando_lookup_misses_total 1
+# HELP test_expando_lookups_total Number of variables looked up in an Environment.
+# TYPE test_expando_lookups_total counter
+test_expando_lookups_total 2
+# HELP test_expando_placeholders_resolved_total Number of variables expanded.
+# TYPE test_expando_placeholders_resolved_total counter
+test_expando_placeholders_resolved_total 2
+# HELP test_expando_templates_expanded_total Number of templates expanded.
+# TYPE test_expando_templates_expanded_total counter
+test_expando_templates_expanded_total 2
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(want)))
+}
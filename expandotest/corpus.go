@@ -0,0 +1,169 @@
+package expandotest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/willabides/expando"
+)
+
+// CorpusCase is a template from a corpus directory with its environment and expected result
+type CorpusCase struct {
+	// Name is the name of the case's files without the extension
+	Name     string
+	Template string
+	Env      expando.MapEnvironment
+	// Want is the expected expansion
+	Want string
+	// WantErr is the expected error message. Want is ignored when it isn't empty.
+	WantErr string
+}
+
+// ExpandFunc expands a template against an environment
+type ExpandFunc func(tmpl string, env expando.Environment) (string, error)
+
+// LoadCorpus loads the cases in dir. Each case is a file named <name>.tmpl with the template, an optional <name>.env
+// with the environment as KEY=VALUE lines, and either <name>.golden with the expected expansion or <name>.err with the
+// expected error message. Blank lines and lines starting with "#" in .env files are ignored.
+func LoadCorpus(dir string) ([]CorpusCase, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	cases := make([]CorpusCase, 0, len(paths))
+	for _, path := range paths {
+		c, err := loadCorpusCase(strings.TrimSuffix(path, ".tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, *c)
+	}
+	return cases, nil
+}
+
+func loadCorpusCase(base string) (*CorpusCase, error) {
+	tmpl, err := os.ReadFile(base + ".tmpl")
+	if err != nil {
+		return nil, err
+	}
+	c := &CorpusCase{
+		Name:     filepath.Base(base),
+		Template: string(tmpl),
+	}
+	c.Env, err = readEnvFile(base + ".env")
+	if err != nil {
+		return nil, err
+	}
+	golden, err := os.ReadFile(base + ".golden")
+	if err == nil {
+		c.Want = string(golden)
+		return c, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	wantErr, err := os.ReadFile(base + ".err")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: no .golden or .err file", c.Name)
+		}
+		return nil, err
+	}
+	c.WantErr = strings.TrimSpace(string(wantErr))
+	return c, nil
+}
+
+// readEnvFile reads an environment of KEY=VALUE lines. A missing file is an empty environment.
+func readEnvFile(path string) (expando.MapEnvironment, error) {
+	env := expando.MapEnvironment{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return env, nil
+		}
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		eq := strings.IndexByte(text, '=')
+		if eq < 1 {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, line)
+		}
+		env[text[:eq]] = text[eq+1:]
+	}
+	return env, scanner.Err()
+}
+
+// RunCorpus runs every case in dir through expand as a subtest of t
+func RunCorpus(t *testing.T, dir string, expand ExpandFunc) {
+	t.Helper()
+	cases, err := LoadCorpus(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			got, err := expand(c.Template, c.Env)
+			switch {
+			case c.WantErr != "" && err == nil:
+				t.Errorf("want error %q\ngot: %q", c.WantErr, got)
+			case c.WantErr != "" && err.Error() != c.WantErr:
+				t.Errorf("want error %q\ngot error: %q", c.WantErr, err.Error())
+			case c.WantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case c.WantErr == "" && got != c.Want:
+				t.Errorf("want: %q\ngot:  %q", c.Want, got)
+			}
+		})
+	}
+}
+
+// RecordCorpus records the current results of expand as the expectations for every template in dir. It writes
+// <name>.golden for templates that expand and <name>.err for templates that fail, and removes the other file.
+func RecordCorpus(dir string, expand ExpandFunc) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		base := strings.TrimSuffix(path, ".tmpl")
+		tmpl, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		env, err := readEnvFile(base + ".env")
+		if err != nil {
+			return err
+		}
+		write, remove := base+".golden", base+".err"
+		got, err := expand(string(tmpl), env)
+		if err != nil {
+			write, remove = remove, write
+			got = err.Error() + "\n"
+		}
+		err = os.WriteFile(write, []byte(got), 0o644) //nolint:gosec // golden files are checked in with other source files
+		if err != nil {
+			return err
+		}
+		err = os.Remove(remove)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
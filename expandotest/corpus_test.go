@@ -0,0 +1,57 @@
+package expandotest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/expando"
+)
+
+func expandTemplate(tmpl string, env expando.Environment) (string, error) {
+	return expando.ExpandString(tmpl, env)
+}
+
+func TestLoadCorpus(t *testing.T) {
+	cases, err := LoadCorpus("testdata/corpus")
+	require.NoError(t, err)
+	require.Equal(t, []CorpusCase{
+		{
+			Name:     "invalid",
+			Template: "${0}",
+			Env:      expando.MapEnvironment{},
+			WantErr:  `invalid syntax at position 2 of "${0}": invalid starting character`,
+		},
+		{
+			Name:     "url",
+			Template: "https://${HOST}:${PORT|443}/\n",
+			Env:      expando.MapEnvironment{"HOST": "example.com"},
+			Want:     "https://example.com:443/\n",
+		},
+	}, cases)
+}
+
+func TestRunCorpus(t *testing.T) {
+	RunCorpus(t, "testdata/corpus", expandTemplate)
+}
+
+func TestRecordCorpus(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	write("a.tmpl", "${A}")
+	write("a.env", "A=1\n")
+	write("a.err", "stale")
+	write("b.tmpl", "${")
+	require.NoError(t, RecordCorpus(dir, expandTemplate))
+
+	got, err := os.ReadFile(filepath.Join(dir, "a.golden"))
+	require.NoError(t, err)
+	require.Equal(t, "1", string(got))
+	require.NoFileExists(t, filepath.Join(dir, "a.err"))
+	require.FileExists(t, filepath.Join(dir, "b.err"))
+	RunCorpus(t, dir, expandTemplate)
+}
@@ -0,0 +1,95 @@
+// Package expandotest has helpers for testing code that uses expando.
+package expandotest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// FakeEnvironment is an expando.Environment for tests that declare which variables a template should look up. Looking
+// up a variable that wasn't declared fails the test immediately, and declared variables that were never looked up
+// fail the test when it finishes. It is safe for concurrent use.
+type FakeEnvironment struct {
+	t        testing.TB
+	mu       sync.Mutex
+	expected map[string]fakeVar
+	looked   map[string]bool
+}
+
+type fakeVar struct {
+	value string
+	set   bool
+}
+
+// NewFakeEnvironment returns a FakeEnvironment that reports failures to t and checks for missing lookups when t
+// finishes.
+func NewFakeEnvironment(t testing.TB) *FakeEnvironment {
+	t.Helper()
+	f := &FakeEnvironment{
+		t:        t,
+		expected: map[string]fakeVar{},
+		looked:   map[string]bool{},
+	}
+	t.Cleanup(f.checkMissing)
+	return f
+}
+
+// Expect declares that name will be looked up and has the given value. It returns f so calls can be chained.
+func (f *FakeEnvironment) Expect(name, value string) *FakeEnvironment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expected[name] = fakeVar{value: value, set: true}
+	return f
+}
+
+// ExpectUnset declares that name will be looked up and is unset. It returns f so calls can be chained.
+func (f *FakeEnvironment) ExpectUnset(name string) *FakeEnvironment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expected[name] = fakeVar{}
+	return f
+}
+
+// LookupEnv implements expando.Environment. It fails the test when key wasn't declared with Expect or ExpectUnset.
+func (f *FakeEnvironment) LookupEnv(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.expected[key]
+	if !ok {
+		f.t.Errorf("unexpected lookup of %q\nexpected lookups: %s", key, quotedNames(f.expected))
+		return "", false
+	}
+	f.looked[key] = true
+	return v.value, v.set
+}
+
+// checkMissing fails the test when any declared variable wasn't looked up
+func (f *FakeEnvironment) checkMissing() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	missing := map[string]fakeVar{}
+	for name, v := range f.expected {
+		if !f.looked[name] {
+			missing[name] = v
+		}
+	}
+	if len(missing) > 0 {
+		f.t.Errorf("expected lookups that didn't happen: %s", quotedNames(missing))
+	}
+}
+
+// quotedNames returns the sorted, quoted keys of vars separated by commas
+func quotedNames(vars map[string]fakeVar) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, fmt.Sprintf("%q", name))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,53 @@
+package expandotest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/expando"
+)
+
+// recordingTB records the failures and cleanups of a test
+type recordingTB struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Cleanup(fn func()) {
+	r.cleanups = append(r.cleanups, fn)
+}
+
+func (r *recordingTB) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestFakeEnvironment(t *testing.T) {
+	t.Run("expected lookups", func(t *testing.T) {
+		env := NewFakeEnvironment(t).Expect("HOST", "example.com").ExpectUnset("PORT")
+		result, err := expando.Expand(`${HOST}:${PORT|80}`, env, nil)
+		require.NoError(t, err)
+		require.Equal(t, "example.com:80", string(result))
+	})
+
+	t.Run("unexpected and missing lookups", func(t *testing.T) {
+		tb := &recordingTB{}
+		env := NewFakeEnvironment(tb).Expect("HOST", "example.com").ExpectUnset("PORT").Expect("USER", "")
+		_, err := expando.Expand(`${HOST} ${PATH}`, env, nil)
+		require.NoError(t, err)
+		tb.finish()
+		require.Equal(t, []string{
+			"unexpected lookup of \"PATH\"\nexpected lookups: \"HOST\", \"PORT\", \"USER\"",
+			`expected lookups that didn't happen: "PORT", "USER"`,
+		}, tb.errors)
+	})
+}
@@ -0,0 +1,155 @@
+package expandotest
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/willabides/expando"
+)
+
+// Case is a generated template with an environment to expand it against and the expected result
+type Case struct {
+	Template string
+	Env      expando.MapEnvironment
+	Want     string
+}
+
+const (
+	nameStart = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	nameChars = nameStart + "0123456789_"
+	// valueChars are used in values and default values. They have no newline so environments survive FormatEnv.
+	valueChars = nameChars + " {}|\\-.:/"
+	// textChars are used in literal text. "$" is handled separately.
+	textChars = valueChars + "\n"
+)
+
+// RandomName returns a random valid variable name
+func RandomName(r *rand.Rand) string {
+	n := 1 + r.Intn(8)
+	b := make([]byte, n)
+	b[0] = nameStart[r.Intn(len(nameStart))]
+	for i := 1; i < n; i++ {
+		b[i] = nameChars[r.Intn(len(nameChars))]
+	}
+	return string(b)
+}
+
+// RandomEnvironment returns an environment with up to n random variables
+func RandomEnvironment(r *rand.Rand, n int) expando.MapEnvironment {
+	env := make(expando.MapEnvironment, n)
+	for i := 0; i < n; i++ {
+		env[RandomName(r)] = randomString(r, valueChars, 12)
+	}
+	return env
+}
+
+// RandomCase returns a random valid template with a random environment and the expected expansion. The template mixes
+// every part of the syntax: literal text, "$$", variables that are set and unset, and default values with escapes.
+func RandomCase(r *rand.Rand) Case {
+	env := RandomEnvironment(r, r.Intn(6))
+	names := make([]string, 0, len(env)+3)
+	for name := range env {
+		names = append(names, name)
+	}
+	// sort for reproducible results from a seed
+	sort.Strings(names)
+	for i := 0; i < 3; i++ {
+		name := RandomName(r)
+		if _, ok := env[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	var tmpl, want strings.Builder
+	for parts := r.Intn(10); parts > 0; parts-- {
+		switch r.Intn(4) {
+		case 0:
+			text := randomString(r, textChars, 10)
+			tmpl.WriteString(text)
+			want.WriteString(text)
+		case 1:
+			tmpl.WriteString("$$")
+			want.WriteString("$")
+		case 2:
+			name := names[r.Intn(len(names))]
+			tmpl.WriteString("${" + name + "}")
+			want.WriteString(env[name])
+		default:
+			name := names[r.Intn(len(names))]
+			def := randomString(r, valueChars, 10)
+			tmpl.WriteString("${" + name + "|" + expando.EscapeDefault(def) + "}")
+			val, ok := env[name]
+			if !ok {
+				val = def
+			}
+			want.WriteString(val)
+		}
+	}
+	return Case{
+		Template: tmpl.String(),
+		Env:      env,
+		Want:     want.String(),
+	}
+}
+
+// CheckExpand runs n random cases from seed through expand and fails t with the template, environment and results
+// of every case where expand doesn't return the expected expansion. Use it to check that a wrapper around expando
+// keeps the same behavior.
+func CheckExpand(t testing.TB, seed int64, n int, expand func(tmpl string, env expando.Environment) (string, error)) {
+	t.Helper()
+	r := rand.New(rand.NewSource(seed)) //nolint:gosec // not for security
+	for i := 0; i < n; i++ {
+		c := RandomCase(r)
+		got, err := expand(c.Template, c.Env)
+		if err != nil {
+			t.Errorf("expanding %q with env %v: %v", c.Template, c.Env, err)
+			continue
+		}
+		if got != c.Want {
+			t.Errorf("expanding %q with env %v\nwant: %q\ngot:  %q", c.Template, c.Env, c.Want, got)
+		}
+	}
+}
+
+// FormatEnv returns env as KEY=VALUE lines sorted by key, the format ParseEnv reads. The environments from
+// RandomEnvironment and RandomCase can be formatted, but values with newlines can't.
+func FormatEnv(env expando.MapEnvironment) string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(key + "=" + env[key] + "\n")
+	}
+	return sb.String()
+}
+
+// ParseEnv parses KEY=VALUE lines. Spaces around keys are trimmed, and lines without a "=" or a key are ignored, so
+// any string is an environment. It is for fuzz tests that take an environment as a string argument.
+func ParseEnv(text string) expando.MapEnvironment {
+	env := expando.MapEnvironment{}
+	for _, line := range strings.Split(text, "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		if key == "" {
+			continue
+		}
+		env[key] = parts[1]
+	}
+	return env
+}
+
+func randomString(r *rand.Rand, chars string, max int) string {
+	b := make([]byte, r.Intn(max+1))
+	for i := range b {
+		b[i] = chars[r.Intn(len(chars))]
+	}
+	return string(b)
+}
@@ -0,0 +1,46 @@
+package expandotest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/expando"
+)
+
+func TestRandomCase(t *testing.T) {
+	r1 := rand.New(rand.NewSource(1))
+	r2 := rand.New(rand.NewSource(1))
+	require.Equal(t, RandomCase(r1), RandomCase(r2))
+}
+
+func TestParseEnv(t *testing.T) {
+	got := ParseEnv(`
+FOO=bar
+ BAZ 	=qux
+asdf
+
+`)
+	require.Equal(t, expando.MapEnvironment{
+		"FOO": "bar",
+		"BAZ": "qux",
+	}, got)
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		env := RandomEnvironment(r, 5)
+		require.Equal(t, env, ParseEnv(FormatEnv(env)))
+	}
+}
+
+func TestCheckExpand(t *testing.T) {
+	CheckExpand(t, 1, 1000, func(tmpl string, env expando.Environment) (string, error) {
+		return expando.ExpandString(tmpl, env)
+	})
+
+	tb := &recordingTB{}
+	CheckExpand(tb, 1, 100, func(tmpl string, env expando.Environment) (string, error) {
+		return tmpl, nil
+	})
+	require.NotEmpty(t, tb.errors)
+}
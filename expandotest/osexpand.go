@@ -0,0 +1,88 @@
+package expandotest
+
+import (
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Divergence is a template that os.Expand or os.ExpandEnv expands differently than the function it is compared with
+type Divergence struct {
+	Template string
+	// Want is the expansion from the standard library and Got is the expansion it was compared with
+	Want, Got string
+}
+
+// CompareOSExpand expands each template with os.Expand and with expand, which is usually osexpand.Expand or a wrapper
+// around an Expander configured WithOSSyntax, and returns the templates where the results differ. mapping is passed to
+// both.
+func CompareOSExpand(templates []string, mapping func(string) string, expand func(s string, mapping func(string) string) string) []Divergence {
+	var divergences []Divergence
+	for _, tmpl := range templates {
+		want := os.Expand(tmpl, mapping)
+		got := expand(tmpl, mapping)
+		if got != want {
+			divergences = append(divergences, Divergence{Template: tmpl, Want: want, Got: got})
+		}
+	}
+	return divergences
+}
+
+// CompareOSExpandEnv is CompareOSExpand for os.ExpandEnv and expandEnv, which is usually osexpand.ExpandEnv. Both
+// expand templates with the current environment variables.
+func CompareOSExpandEnv(templates []string, expandEnv func(s string) string) []Divergence {
+	var divergences []Divergence
+	for _, tmpl := range templates {
+		want := os.ExpandEnv(tmpl)
+		got := expandEnv(tmpl)
+		if got != want {
+			divergences = append(divergences, Divergence{Template: tmpl, Want: want, Got: got})
+		}
+	}
+	return divergences
+}
+
+// osParts are the pieces RandomOSTemplate builds templates from. They cover valid and invalid os.Expand syntax.
+var osParts = []string{
+	"$", "$$", "${", "}", "{", "${}", "$*", "$@", "$#", "$1", "${1}", "${*}", "$-", "${a b}", "${{a}}", "$.", " ", "\n",
+}
+
+// RandomOSTemplate returns a random template in os.Expand syntax. It mixes literal text, $var and ${var} references,
+// shell special variables, and invalid syntax that os.Expand leaves out of the output.
+func RandomOSTemplate(r *rand.Rand) string {
+	var tmpl strings.Builder
+	for parts := r.Intn(10); parts > 0; parts-- {
+		switch r.Intn(4) {
+		case 0:
+			tmpl.WriteString(randomString(r, textChars, 6))
+		case 1:
+			tmpl.WriteString("$" + RandomName(r))
+		case 2:
+			tmpl.WriteString("${" + RandomName(r) + "}")
+		default:
+			tmpl.WriteString(osParts[r.Intn(len(osParts))])
+		}
+	}
+	return tmpl.String()
+}
+
+// CheckOSExpand compares n random templates from seed with CompareOSExpand and fails t with every divergence. Each
+// variable expands to its name in angle brackets, and the empty name used by invalid syntax expands to "EMPTY".
+func CheckOSExpand(t testing.TB, seed int64, n int, expand func(s string, mapping func(string) string) string) {
+	t.Helper()
+	r := rand.New(rand.NewSource(seed)) //nolint:gosec // not for security
+	templates := make([]string, n)
+	for i := range templates {
+		templates[i] = RandomOSTemplate(r)
+	}
+	mapping := func(name string) string {
+		if name == "" {
+			return "EMPTY"
+		}
+		return "<" + name + ">"
+	}
+	for _, d := range CompareOSExpand(templates, mapping, expand) {
+		t.Errorf("expanding %q\nos.Expand: %q\ngot:       %q", d.Template, d.Want, d.Got)
+	}
+}
@@ -0,0 +1,57 @@
+package expandotest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/expando"
+	"github.com/willabides/expando/osexpand"
+)
+
+func nativeExpand(s string, mapping func(string) string) string {
+	got, err := expando.ExpandString(s, mappingEnv(mapping))
+	if err != nil {
+		return err.Error()
+	}
+	return got
+}
+
+type mappingEnv func(string) string
+
+func (fn mappingEnv) LookupEnv(key string) (string, bool) {
+	return fn(key), true
+}
+
+func TestCompareOSExpand(t *testing.T) {
+	templates := []string{"$FOO", "${FOO}", "$$"}
+	mapping := func(name string) string {
+		return "<" + name + ">"
+	}
+	require.Empty(t, CompareOSExpand(templates, mapping, osexpand.Expand))
+	require.Equal(t, []Divergence{
+		{Template: "$FOO", Want: "<FOO>", Got: "$FOO"},
+		{Template: "$$", Want: "<$>", Got: "$"},
+	}, CompareOSExpand(templates, mapping, nativeExpand))
+}
+
+func TestCompareOSExpandEnv(t *testing.T) {
+	t.Setenv("EXPANDOTEST_FOO", "foo")
+	templates := []string{"$EXPANDOTEST_FOO", "${EXPANDOTEST_FOO}", "$EXPANDOTEST_UNSET"}
+	require.Empty(t, CompareOSExpandEnv(templates, osexpand.ExpandEnv))
+	require.Equal(t, []Divergence{
+		{Template: "$EXPANDOTEST_FOO", Want: "foo", Got: "$EXPANDOTEST_FOO"},
+		{Template: "$EXPANDOTEST_UNSET", Want: "", Got: "$EXPANDOTEST_UNSET"},
+	}, CompareOSExpandEnv(templates, func(s string) string {
+		got, err := expando.ExpandString(s, expando.OSEnv)
+		require.NoError(t, err)
+		return got
+	}))
+}
+
+func TestCheckOSExpand(t *testing.T) {
+	CheckOSExpand(t, 1, 10000, osexpand.Expand)
+
+	tb := &recordingTB{}
+	CheckOSExpand(tb, 1, 100, nativeExpand)
+	require.NotEmpty(t, tb.errors)
+}
@@ -0,0 +1,12 @@
+// Command expandovet reports invalid constant templates passed to expando. It can be run on its own or with
+// "go vet -vettool=$(which expandovet)".
+package main
+
+import (
+	"github.com/willabides/expando/expandovet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(expandovet.Analyzer)
+}
@@ -0,0 +1,126 @@
+// Package expandovet defines an Analyzer that reports invalid constant templates passed to expando.
+package expandovet
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/willabides/expando"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Analyzer reports calls to expando functions and methods with a constant template that isn't valid.
+var Analyzer = &analysis.Analyzer{
+	Name:     "expandovet",
+	Doc:      "report invalid constant templates passed to expando",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+const expandoPath = "github.com/willabides/expando"
+
+// templateArgs maps the names of functions and methods in expando to the index of their template argument. Methods
+// are only checked when the receiver is an Expander without options because options like WithConstraints and
+// WithOSSyntax change what a valid template is.
+var templateArgs = map[string]int{
+	"Expand":                    0,
+	"ExpandContext":             1,
+	"ExpandEnv":                 0,
+	"ExpandString":              0,
+	"ExpandAppendN":             2,
+	"Parse":                     0,
+	"ListVars":                  0,
+	"(*Expander).Expand":        0,
+	"(*Expander).ExpandContext": 1,
+	"(*Expander).ExpandRegions": 0,
+	"(*Expander).ExpandAppendN": 2,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	insp.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		i, ok := templateArg(pass, call)
+		if !ok || i >= len(call.Args) {
+			return
+		}
+		arg := call.Args[i]
+		tv := pass.TypesInfo.Types[arg]
+		if tv.Value == nil || tv.Value.Kind() != constant.String {
+			return
+		}
+		_, err := expando.Expand(constant.StringVal(tv.Value), expando.MapEnvironment{}, nil)
+		if err != nil {
+			pass.Reportf(arg.Pos(), "invalid expando template: %v", err)
+		}
+	})
+	return nil, nil
+}
+
+// templateArg returns the index of the template argument when call is a call to an expando function or method
+func templateArg(pass *analysis.Pass, call *ast.CallExpr) (int, bool) {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return 0, false
+	}
+	fn, ok := pass.TypesInfo.Uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != expandoPath {
+		return 0, false
+	}
+	name := fn.Name()
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		ptr, ok := recv.Type().(*types.Pointer)
+		if !ok {
+			return 0, false
+		}
+		named, ok := ptr.Elem().(*types.Named)
+		if !ok {
+			return 0, false
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !isDefaultExpander(pass, sel.X) {
+			return 0, false
+		}
+		name = "(*" + named.Obj().Name() + ")." + name
+	}
+	i, ok := templateArgs[name]
+	return i, ok
+}
+
+// isDefaultExpander reports whether expr is an Expander without options: Expander{}, &Expander{} or NewExpander()
+func isDefaultExpander(pass *analysis.Pass, expr ast.Expr) bool {
+	expr = astutil.Unparen(expr)
+	if u, ok := expr.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		expr = astutil.Unparen(u.X)
+	}
+	switch expr := expr.(type) {
+	case *ast.CompositeLit:
+		named, ok := pass.TypesInfo.TypeOf(expr).(*types.Named)
+		return ok && len(expr.Elts) == 0 && named.Obj().Pkg() != nil &&
+			named.Obj().Pkg().Path() == expandoPath && named.Obj().Name() == "Expander"
+	case *ast.CallExpr:
+		var id *ast.Ident
+		switch fun := expr.Fun.(type) {
+		case *ast.Ident:
+			id = fun
+		case *ast.SelectorExpr:
+			id = fun.Sel
+		default:
+			return false
+		}
+		fn, ok := pass.TypesInfo.Uses[id].(*types.Func)
+		return ok && len(expr.Args) == 0 && fn.Pkg() != nil && fn.Pkg().Path() == expandoPath &&
+			fn.Name() == "NewExpander"
+	}
+	return false
+}
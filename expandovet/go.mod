@@ -0,0 +1,17 @@
+module github.com/willabides/expando/expandovet
+
+go 1.17
+
+require (
+	github.com/willabides/expando v0.0.0
+	golang.org/x/tools v0.1.11
+)
+
+require (
+	golang.org/x/mod v0.6.0-dev.0.20220419223038-86c51ed26bb4 // indirect
+	golang.org/x/sys v0.0.0-20211019181941-9d821ace8654 // indirect
+)
+
+// expando has no tagged release yet, so this module builds against the expando in the parent directory. Require the
+// first tagged release above and remove this replace once there is one.
+replace github.com/willabides/expando => ../
@@ -0,0 +1,37 @@
+package a
+
+import (
+	"context"
+
+	"github.com/willabides/expando"
+)
+
+const badTemplate = "${foo"
+
+type wrapper struct {
+	*expando.Expander
+}
+
+func calls(ctx context.Context, e *expando.Expander, env expando.Environment, tmpl string) {
+	expando.Expand("${foo} ${bar|baz}", env, nil)
+	expando.Expand("${foo|}", env, nil)
+	expando.Expand(tmpl, env, nil)
+	expando.Expand("${1foo}", env, nil)                // want `invalid expando template: invalid syntax at position 2 of "\${1foo": invalid starting character`
+	expando.ExpandEnv(badTemplate, nil)                // want `invalid expando template: invalid syntax at position 5 of "\${foo": unterminated`
+	expando.ExpandString("${foo|\\x}", env)            // want `invalid expando template: invalid syntax at position 7 of "\${foo|\\\\x}": invalid escape sequence`
+	expando.ExpandContext(ctx, "${foo bar}", env, nil) // want `invalid expando template: .*invalid character`
+	expando.ExpandString("${foo}}", env)
+	expando.ExpandAppendN(nil, 0, "${}", env) // want `invalid expando template: .*empty string`
+	expando.Parse("${x")                      // want `invalid expando template: .*unterminated`
+	expando.ListVars("${x|")                  // want `invalid expando template: .*unterminated`
+
+	// methods are only checked for Expanders without options
+	e.Expand("a ${b-c}", env, nil)
+	expando.NewExpander(expando.WithConstraints()).Expand("${PORT~int}", env, nil)
+	wrapper{}.Expand("${b-c}", env, nil)
+	expando.NewExpander().Expand("a ${b-c}", env, nil)                // want `invalid expando template: .*invalid character`
+	(&expando.Expander{}).ExpandAppendN(nil, 0, "${"+"x", env)        // want `invalid expando template: .*unterminated`
+	(&expando.Expander{}).ExpandContext(ctx, "${PORT~int}", env, nil) // want `invalid expando template: .*invalid character`
+	expando.NewExpander().ExpandRegions("${", env, nil)               // want `invalid expando template: .*unterminated`
+	expando.NewExpander().ExpandAppendN(nil, 0, "${x}", env)
+}
@@ -0,0 +1,63 @@
+// Package expando is a stub of github.com/willabides/expando for tests
+package expando
+
+import "context"
+
+type Environment interface {
+	LookupEnv(string) (string, bool)
+}
+
+type MapEnvironment map[string]string
+
+func (m MapEnvironment) LookupEnv(key string) (string, bool) {
+	val, ok := m[key]
+	return val, ok
+}
+
+func Expand(tmpl string, lookupEnv Environment, buf []byte) ([]byte, error) { return nil, nil }
+
+func ExpandContext(ctx context.Context, tmpl string, lookupEnv Environment, buf []byte) ([]byte, error) {
+	return nil, nil
+}
+
+func ExpandEnv(tmpl string, buf []byte) ([]byte, error) { return nil, nil }
+
+func ExpandString(tmpl string, lookupEnv Environment) (string, error) { return "", nil }
+
+func ExpandAppendN(dst []byte, max int, tmpl string, lookupEnv Environment) ([]byte, error) {
+	return nil, nil
+}
+
+type Template struct{}
+
+func Parse(tmpl string) (*Template, error) { return nil, nil }
+
+type Var struct{}
+
+func ListVars(tmpl string) ([]Var, error) { return nil, nil }
+
+type Region struct{}
+
+type Option func(*Expander)
+
+func WithConstraints() Option { return nil }
+
+type Expander struct{}
+
+func NewExpander(options ...Option) *Expander { return nil }
+
+func (e *Expander) Expand(tmpl string, lookupEnv Environment, buf []byte) ([]byte, error) {
+	return nil, nil
+}
+
+func (e *Expander) ExpandContext(ctx context.Context, tmpl string, lookupEnv Environment, buf []byte) ([]byte, error) {
+	return nil, nil
+}
+
+func (e *Expander) ExpandRegions(tmpl string, lookupEnv Environment, buf []byte) ([]byte, []Region, error) {
+	return nil, nil, nil
+}
+
+func (e *Expander) ExpandAppendN(dst []byte, max int, tmpl string, lookupEnv Environment) ([]byte, error) {
+	return nil, nil
+}
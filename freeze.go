@@ -0,0 +1,58 @@
+package expando
+
+import "strings"
+
+// FreezeDefaults returns tmpl with the default value of every variable that is set in env replaced with its current
+// value, so the template keeps working the same way in an environment where the variable is unset. Placeholders stay
+// in the template, so the variables can still be overridden. Variables that are unset in env are left as they are.
+func FreezeDefaults(tmpl string, env Environment) (string, error) {
+	return rewriteVars(tmpl, env, func(name, val string) string {
+		return "${" + name + "|" + EscapeDefault(val) + "}"
+	})
+}
+
+// InlineValues returns tmpl with every variable that is set in env replaced with its current value. Dollar signs in
+// values are escaped, so the result is a template that expands to the same text. Variables that are unset in env are
+// left as they are.
+func InlineValues(tmpl string, env Environment) (string, error) {
+	return rewriteVars(tmpl, env, func(_, val string) string {
+		return strings.ReplaceAll(val, "$", "$$")
+	})
+}
+
+// rewriteVars returns tmpl with every variable that is set in env replaced by rewrite
+func rewriteVars(tmpl string, env Environment, rewrite func(name, val string) string) (string, error) {
+	var sb strings.Builder
+	sb.Grow(len(tmpl))
+	s := scanner{tmpl: tmpl}
+	for {
+		start := s.pos
+		kind, err := s.next()
+		if err != nil {
+			return "", err
+		}
+		switch kind {
+		case tokenEOF:
+			return sb.String(), nil
+		case tokenLiteral:
+			// the original text, which keeps "$$" escaped
+			sb.WriteString(tmpl[start:s.pos])
+		case tokenVar:
+			sb.WriteString(s.text)
+			val, ok := env.LookupEnv(s.name)
+			if !ok {
+				sb.WriteString(tmpl[start+len(s.text) : s.pos])
+				continue
+			}
+			sb.WriteString(rewrite(s.name, val))
+		}
+	}
+}
+
+// EscapeDefault escapes "}" and "\" in s so "${NAME|" + EscapeDefault(s) + "}" has s as its default value
+func EscapeDefault(s string) string {
+	if !strings.ContainsAny(s, `}\`) {
+		return s
+	}
+	return strings.NewReplacer(`\`, `\\`, `}`, `\}`).Replace(s)
+}
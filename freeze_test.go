@@ -0,0 +1,44 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreezeDefaults(t *testing.T) {
+	env := MapEnvironment{"HOST": "example.com", "PATH": `c:\bin}`}
+	got, err := FreezeDefaults(`$$ ${HOST}:${PORT|80} ${PATH|/bin} ${USER}`, env)
+	require.NoError(t, err)
+	require.Equal(t, `$$ ${HOST|example.com}:${PORT|80} ${PATH|c:\\bin\}} ${USER}`, got)
+
+	want, err := Expand(`$$ ${HOST}:${PORT|80} ${PATH|/bin} ${USER}`, env, nil)
+	require.NoError(t, err)
+	frozen, err := Expand(got, MapEnvironment{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(frozen))
+
+	_, err = FreezeDefaults(`${`, env)
+	require.Error(t, err)
+}
+
+func TestInlineValues(t *testing.T) {
+	env := MapEnvironment{"HOST": "example.com", "PRICE": "$5"}
+	got, err := InlineValues(`$$ ${HOST}:${PORT|80} ${PRICE}`, env)
+	require.NoError(t, err)
+	require.Equal(t, `$$ example.com:${PORT|80} $$5`, got)
+
+	expanded, err := Expand(got, MapEnvironment{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, `$ example.com:80 $5`, string(expanded))
+}
+
+func TestEscapeDefault(t *testing.T) {
+	for _, val := range []string{"", "plain", `a}b`, `a\b`, `\}`, `}}\\`, "$x"} {
+		escaped := EscapeDefault(val)
+		result, err := Expand("${UNSET|"+escaped+"}", MapEnvironment{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, val, string(result))
+	}
+	require.Equal(t, `a\}b\\`, EscapeDefault(`a}b\`))
+}
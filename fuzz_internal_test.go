@@ -0,0 +1,107 @@
+//go:build gofuzzbeta
+
+package expando
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// CheckFuzzProperties checks the properties of the parser's internals that hold for any data. It is exported for Fuzz in
+// fuzz_test.go, which is in expando_test so it can use expandotest.
+func CheckFuzzProperties(t *testing.T, data string, env Environment) {
+	t.Helper()
+	fuzzExpand(t, data, env)
+	testVarInfoProperties(t, data)
+	testReadVarNameProperties(t, data)
+	testSkipDefaultValueProperties(t, data)
+	testOSSyntaxProperties(t, data)
+}
+
+func testOSSyntaxProperties(t *testing.T, tmpl string) {
+	t.Helper()
+	mapping := func(name string) string {
+		return "<" + name + ">"
+	}
+	got, err := NewExpander(WithOSSyntax()).Expand(tmpl, envFunc(func(name string) (string, bool) {
+		return mapping(name), true
+	}), nil)
+	require.NoError(t, err)
+	require.Equal(t, os.Expand(tmpl, mapping), string(got))
+}
+
+func fuzzExpand(t *testing.T, tmpl string, env Environment) {
+	// nolint:errcheck // we are just checking for panics
+	_, _ = Expand(tmpl, env, nil)
+}
+
+func testSkipDefaultValueProperties(t *testing.T, data string) {
+	t.Helper()
+
+	val, valLen, escaped, err := skipDefaultValue(data)
+	if err == nil {
+		require.True(t, len(val) < valLen)
+		require.Equal(t, strings.Contains(val, `\`), escaped)
+		require.Equal(t, stripChars(val, `\}`), stripChars(unescapeDefault(val), `\}`))
+	}
+	if len(val) > 0 {
+		require.True(t, strings.HasPrefix(data, val))
+	}
+}
+
+func testVarInfoProperties(t *testing.T, data string) {
+	if data == "" {
+		return
+	}
+	name, defaultValue, _, w, err := varInfo(data)
+	_, _ = name, defaultValue
+	switch err {
+	case errUnterminated:
+		require.True(t, !regexp.MustCompile(`[^\\]}`).MatchString(data))
+		require.True(t, data[0] != '}')
+	case errEmptyString:
+		require.True(t, data[0] == '}' || data[0] == '|')
+	case errInvalidStartingCharacter:
+		require.True(t, !validNameFirstChar(data[0]))
+	case errInvalidCharacter:
+		require.True(t, !validNameChar(data[w]))
+	case nil:
+		require.True(t, len(name)+len(defaultValue) < w)
+		require.True(t, data[w-1] == '}')
+		require.True(t, regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`).MatchString(name))
+	}
+}
+
+func testReadVarNameProperties(t *testing.T, data string) {
+	t.Helper()
+	name, nameLen, err := readVarName(data)
+	switch err {
+	case nil:
+		require.True(t, len(name) < nameLen)
+		require.True(t, data[nameLen-1] == '}' || data[nameLen-1] == '|')
+		require.True(t, regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`).MatchString(name))
+	case errEmptyString:
+		require.True(t, data[0] == '}' || data[0] == '|')
+	case errInvalidStartingCharacter:
+		require.True(t, !validNameFirstChar(data[0]))
+	case errInvalidCharacter:
+		require.True(t, !validNameChar(data[nameLen]))
+	case errUnterminated:
+		require.True(t, !strings.Contains(data, "}"))
+		require.True(t, !strings.Contains(data, "|"))
+	}
+	if len(name) > 0 {
+		require.True(t, strings.HasPrefix(data, name))
+	}
+}
+
+func stripChars(data, chars string) string {
+	for i := range chars {
+		data = strings.ReplaceAll(data, string(chars[i]), "")
+	}
+	return data
+}
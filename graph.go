@@ -0,0 +1,76 @@
+package expando
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VarGraph is the graph of which templates reference which variables. It marshals to JSON as an object with "templates"
+// and "variables" properties.
+type VarGraph struct {
+	// Templates maps each template name to the variables it references, sorted
+	Templates map[string][]string `json:"templates"`
+
+	// Variables maps each variable to the templates that reference it, sorted
+	Variables map[string][]string `json:"variables"`
+}
+
+// DependencyGraph returns the VarGraph for templates. Every template is in the graph, including templates that don't
+// reference any variables.
+func DependencyGraph(templates Templates) (*VarGraph, error) {
+	vars, err := collectVars(templates)
+	if err != nil {
+		return nil, err
+	}
+	g := &VarGraph{
+		Templates: make(map[string][]string, len(templates)),
+		Variables: make(map[string][]string, len(vars)),
+	}
+	for name := range templates {
+		g.Templates[name] = []string{}
+	}
+	// vars is sorted by name, so each template's variables are added in order
+	for _, v := range vars {
+		g.Variables[v.name] = v.templates
+		for _, tmplName := range v.templates {
+			g.Templates[tmplName] = append(g.Templates[tmplName], v.name)
+		}
+	}
+	return g, nil
+}
+
+// DOT returns the graph in Graphviz DOT format with an edge from each template to each variable it references.
+// Templates are drawn as boxes and variables as ellipses.
+func (g *VarGraph) DOT() []byte {
+	var sb strings.Builder
+	sb.WriteString("digraph vars {\n")
+	for _, name := range sortedKeys(g.Templates) {
+		sb.WriteString("\t" + dotID("template", name) + " [label=" + strconv.Quote(name) + " shape=box];\n")
+	}
+	for _, name := range sortedKeys(g.Variables) {
+		sb.WriteString("\t" + dotID("var", name) + " [label=" + strconv.Quote(name) + "];\n")
+	}
+	for _, tmplName := range sortedKeys(g.Templates) {
+		for _, varName := range g.Templates[tmplName] {
+			sb.WriteString("\t" + dotID("template", tmplName) + " -> " + dotID("var", varName) + ";\n")
+		}
+	}
+	sb.WriteString("}\n")
+	return []byte(sb.String())
+}
+
+// dotID returns a quoted DOT node ID for a node of the given kind, so a template and a variable with the same name are
+// different nodes
+func dotID(kind, name string) string {
+	return strconv.Quote(kind + ":" + name)
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
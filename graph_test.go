@@ -0,0 +1,53 @@
+package expando
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	g, err := DependencyGraph(map[string]string{
+		"app.conf": `${HOST}:${PORT|80} ${HOST}`,
+		"db.conf":  `${HOST} ${DB_NAME}`,
+		"static":   `no vars`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, &VarGraph{
+		Templates: map[string][]string{
+			"app.conf": {"HOST", "PORT"},
+			"db.conf":  {"DB_NAME", "HOST"},
+			"static":   {},
+		},
+		Variables: map[string][]string{
+			"DB_NAME": {"db.conf"},
+			"HOST":    {"app.conf", "db.conf"},
+			"PORT":    {"app.conf"},
+		},
+	}, g)
+
+	got, err := json.Marshal(g)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"templates": {"app.conf": ["HOST", "PORT"], "db.conf": ["DB_NAME", "HOST"], "static": []},
+		"variables": {"DB_NAME": ["db.conf"], "HOST": ["app.conf", "db.conf"], "PORT": ["app.conf"]}
+	}`, string(got))
+
+	require.Equal(t, `digraph vars {
+	"template:app.conf" [label="app.conf" shape=box];
+	"template:db.conf" [label="db.conf" shape=box];
+	"template:static" [label="static" shape=box];
+	"var:DB_NAME" [label="DB_NAME"];
+	"var:HOST" [label="HOST"];
+	"var:PORT" [label="PORT"];
+	"template:app.conf" -> "var:HOST";
+	"template:app.conf" -> "var:PORT";
+	"template:db.conf" -> "var:DB_NAME";
+	"template:db.conf" -> "var:HOST";
+}
+`, string(g.DOT()))
+
+	_, err = DependencyGraph(map[string]string{"bad": `${`})
+	require.Error(t, err)
+}
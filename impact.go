@@ -0,0 +1,51 @@
+package expando
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Impact is a template whose output changes between two environments
+type Impact struct {
+	// Template is the name of the template
+	Template string
+
+	// Variables are the variables the template references that have different values in the two environments, sorted
+	Variables []string
+
+	// Old and New are the template's outputs in the old and new environments
+	Old, New string
+}
+
+// CompareEnvironments reports which of templates expand differently with newEnv than with oldEnv, and which variables
+// drive each change. Templates whose output doesn't change aren't reported.
+func CompareEnvironments(templates Templates, oldEnv, newEnv Environment) ([]Impact, error) {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var impacts []Impact
+	for _, name := range names {
+		tmpl := templates[name]
+		oldRecorder, newRecorder := NewRecorder(oldEnv), NewRecorder(newEnv)
+		oldOut, err := Expand(tmpl, oldRecorder, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		newOut, err := Expand(tmpl, newRecorder, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if string(oldOut) == string(newOut) {
+			continue
+		}
+		impacts = append(impacts, Impact{
+			Template:  name,
+			Variables: changedValues(oldRecorder.values, newRecorder.values),
+			Old:       string(oldOut),
+			New:       string(newOut),
+		})
+	}
+	return impacts, nil
+}
@@ -0,0 +1,28 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareEnvironments(t *testing.T) {
+	templates := map[string]string{
+		"db.conf":   `${DB_HOST}:${DB_PORT|5432}`,
+		"app.conf":  `${HOST} ${LEVEL|info} ${DB_HOST}`,
+		"same.conf": `${HOST}`,
+		"static":    `no vars`,
+	}
+	oldEnv := MapEnvironment{"HOST": "a", "DB_HOST": "db1"}
+	newEnv := MapEnvironment{"HOST": "a", "DB_HOST": "db2", "DB_PORT": "5433", "LEVEL": "info"}
+	impacts, err := CompareEnvironments(templates, oldEnv, newEnv)
+	require.NoError(t, err)
+	require.Equal(t, []Impact{
+		{Template: "app.conf", Variables: []string{"DB_HOST", "LEVEL"}, Old: "a info db1", New: "a info db2"},
+		{Template: "db.conf", Variables: []string{"DB_HOST", "DB_PORT"}, Old: "db1:5432", New: "db2:5433"},
+	}, impacts)
+
+	_, err = CompareEnvironments(map[string]string{"bad": `${`}, oldEnv, newEnv)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "bad: ")
+}
@@ -0,0 +1,57 @@
+package expando
+
+import "sort"
+
+// LintReport describes problems with the variables in a set of templates. It is returned by Lint.
+type LintReport struct {
+	// Undefined maps each variable that is referenced without a default value and isn't defined in the environment to
+	// the sorted names of the templates that reference it.
+	Undefined map[string][]string
+
+	// Unused is the sorted names of variables that are defined in the environment but not referenced by any template.
+	Unused []string
+
+	// ConflictingDefaults maps each variable that has more than one default value across the templates to its sorted
+	// default values.
+	ConflictingDefaults map[string][]string
+}
+
+// OK reports whether the report found no problems.
+func (r *LintReport) OK() bool {
+	return len(r.Undefined) == 0 && len(r.Unused) == 0 && len(r.ConflictingDefaults) == 0
+}
+
+// Lint checks the variables referenced by templates against the variables defined in env
+func Lint(templates Templates, env MapEnvironment) (*LintReport, error) {
+	vars, err := collectVars(templates)
+	if err != nil {
+		return nil, err
+	}
+	report := &LintReport{}
+	referenced := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		referenced[v.name] = true
+		if _, ok := env[v.name]; v.required && !ok {
+			if report.Undefined == nil {
+				report.Undefined = map[string][]string{}
+			}
+			report.Undefined[v.name] = v.requiredIn
+		}
+		if len(v.defaults) > 1 {
+			if report.ConflictingDefaults == nil {
+				report.ConflictingDefaults = map[string][]string{}
+			}
+			conflicting := append([]string(nil), v.defaults...)
+			sort.Strings(conflicting)
+			report.ConflictingDefaults[v.name] = conflicting
+		}
+	}
+
+	for key := range env {
+		if !referenced[key] {
+			report.Unused = append(report.Unused, key)
+		}
+	}
+	sort.Strings(report.Unused)
+	return report, nil
+}
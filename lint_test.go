@@ -0,0 +1,46 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	t.Run("problems", func(t *testing.T) {
+		templates := map[string]string{
+			"a.conf": `${HOST}:${PORT|80} ${USER} ${USER} ${LEVEL|info}`,
+			"b.conf": `${PORT|8080} ${USER} ${MODE|}`,
+			"c.conf": `${PORT|80} $${NOT_A_VAR} ${LEVEL|info}`,
+		}
+		env := MapEnvironment{
+			"HOST":   "localhost",
+			"PORT":   "9000",
+			"UNUSED": "x",
+			"OTHER":  "y",
+		}
+		report, err := Lint(templates, env)
+		require.NoError(t, err)
+		require.Equal(t, &LintReport{
+			Undefined: map[string][]string{
+				"USER": {"a.conf", "b.conf"},
+			},
+			Unused: []string{"OTHER", "UNUSED"},
+			ConflictingDefaults: map[string][]string{
+				"PORT": {"80", "8080"},
+			},
+		}, report)
+		require.False(t, report.OK())
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		report, err := Lint(map[string]string{"a": `${HOST} ${PORT|80}`}, MapEnvironment{"HOST": "x"})
+		require.NoError(t, err)
+		require.True(t, report.OK())
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		_, err := Lint(map[string]string{"a": "ok", "b": "${"}, nil)
+		require.EqualError(t, err, `b: invalid syntax at position 2 of "${": unterminated`)
+	})
+}
@@ -0,0 +1,46 @@
+package expando
+
+import "strings"
+
+// MarkdownVars returns a Markdown table documenting the variables referenced by templates. The table has a row for
+// each variable with its default values and the templates that reference it. Variables that are referenced without a
+// default value anywhere are marked as required.
+func MarkdownVars(templates Templates) ([]byte, error) {
+	vars, err := collectVars(templates)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.WriteString("| Variable | Default | Templates |\n")
+	sb.WriteString("|----------|---------|-----------|\n")
+	for _, v := range vars {
+		cells := make([]string, 0, len(v.defaults)+1)
+		if v.required {
+			cells = append(cells, "*required*")
+		}
+		for _, d := range v.defaults {
+			cells = append(cells, markdownCode(d))
+		}
+		tmplNames := make([]string, len(v.templates))
+		for i, name := range v.templates {
+			tmplNames[i] = markdownCode(name)
+		}
+		sb.WriteString("| " + markdownCode(v.name))
+		sb.WriteString(" | " + strings.Join(cells, ", "))
+		sb.WriteString(" | " + strings.Join(tmplNames, ", ") + " |\n")
+	}
+	return []byte(sb.String()), nil
+}
+
+// markdownCode formats s as a code span that is safe to use in a table cell
+func markdownCode(s string) string {
+	s = strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if s == "" || strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		return fence + " " + s + " " + fence
+	}
+	return fence + s + fence
+}
@@ -0,0 +1,41 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdownVars(t *testing.T) {
+	got, err := MarkdownVars(map[string]string{
+		"b|c.conf": `${HOST}:${PORT|8080} ${MODE|}`,
+		"a.conf":   `${PORT|80} ${HOST|localhost}`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "| Variable | Default | Templates |\n"+
+		"|----------|---------|-----------|\n"+
+		"| `HOST` | *required*, `localhost` | `a.conf`, `b\\|c.conf` |\n"+
+		"| `MODE` | `  ` | `b\\|c.conf` |\n"+
+		"| `PORT` | `80`, `8080` | `a.conf`, `b\\|c.conf` |\n", string(got))
+
+	got, err = MarkdownVars(nil)
+	require.NoError(t, err)
+	require.Equal(t, "| Variable | Default | Templates |\n|----------|---------|-----------|\n", string(got))
+}
+
+func Test_markdownCode(t *testing.T) {
+	for _, td := range []struct {
+		in   string
+		want string
+	}{
+		{in: `plain`, want: "`plain`"},
+		{in: ``, want: "`  `"},
+		{in: `a|b`, want: "`a\\|b`"},
+		{in: "a\nb", want: "`a b`"},
+		{in: "a`b", want: "``a`b``"},
+		{in: "a``b`", want: "``` a``b` ```"},
+		{in: "`a", want: "`` `a ``"},
+	} {
+		require.Equal(t, td.want, markdownCode(td.in), td.in)
+	}
+}
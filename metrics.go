@@ -0,0 +1,61 @@
+package expando
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics receives statistics from an Expander configured WithMetrics. ObserveExpansion is called once for every call
+// to one of the Expander's methods, including calls that return an error. Implementations must be safe for concurrent
+// use when the Expander is used concurrently.
+type Metrics interface {
+	ObserveExpansion(stats ExpansionStats)
+}
+
+// ExpansionStats describes the expansion of a single template
+type ExpansionStats struct {
+	// Placeholders is the number of variables in the template that were expanded
+	Placeholders int
+
+	// Lookups is the number of calls to the Environment's LookupEnv. With WithMemoizedLookups it is the number of
+	// distinct variables looked up.
+	Lookups int
+
+	// Misses is the number of Lookups that found no value
+	Misses int
+
+	// Defaults is the number of Placeholders that were replaced with their default value
+	Defaults int
+
+	// Bytes is the number of bytes written when the expansion is successful. It is always 0 when Err is set except
+	// for ExpandStream, which may have written some output before the error.
+	Bytes int
+
+	// Duration is how long the expansion took
+	Duration time.Duration
+
+	// Err is the error returned to the caller, if any
+	Err error
+
+	// TemplateID identifies the template when the context passed to ExpandContext or ExpandStreamContext is from
+	// ContextWithTemplateID. It is empty otherwise.
+	TemplateID string
+}
+
+// templateIDKey is the context key for ContextWithTemplateID
+type templateIDKey struct{}
+
+// ContextWithTemplateID returns a copy of ctx that identifies the template expanded with it to an Expander's Metrics
+// as ExpansionStats.TemplateID. id can be anything that is meaningful to the caller, like a file name or the Hash of a
+// parsed Template.
+func ContextWithTemplateID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, templateIDKey{}, id)
+}
+
+// add adds the counts from other to s
+func (s *ExpansionStats) add(other ExpansionStats) {
+	s.Placeholders += other.Placeholders
+	s.Lookups += other.Lookups
+	s.Misses += other.Misses
+	s.Defaults += other.Defaults
+}
@@ -0,0 +1,31 @@
+// Package osexpand is a drop-in replacement for os.Expand and os.ExpandEnv built on expando. Switching imports from
+// os to osexpand doesn't change any output, and the templates can then be moved to expando syntax gradually.
+package osexpand
+
+import (
+	"os"
+
+	"github.com/willabides/expando"
+)
+
+var expander = expando.NewExpander(expando.WithOSSyntax())
+
+// Expand replaces ${var} or $var in s based on the mapping function exactly like os.Expand.
+func Expand(s string, mapping func(string) string) string {
+	// templates can't be invalid with WithOSSyntax
+	buf, _ := expander.Expand(s, mappingEnv(mapping), nil) //nolint:errcheck
+	return string(buf)
+}
+
+// ExpandEnv replaces ${var} or $var in s according to the values of the current environment variables exactly like
+// os.ExpandEnv.
+func ExpandEnv(s string) string {
+	return Expand(s, os.Getenv)
+}
+
+// mappingEnv is an expando.Environment that has a value for every variable
+type mappingEnv func(string) string
+
+func (fn mappingEnv) LookupEnv(key string) (string, bool) {
+	return fn(key), true
+}
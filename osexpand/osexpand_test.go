@@ -0,0 +1,69 @@
+package osexpand
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var osExpandTests = []string{
+	"",
+	"no vars",
+	"$",
+	"$$",
+	"a$",
+	"$FOO",
+	"$FOO$BAR",
+	"${FOO}",
+	"${FOO}bar",
+	"$FOO-bar",
+	"$FOO_BAR",
+	"${FOO|default}",
+	"${}",
+	"${",
+	"${FOO",
+	"a${}b",
+	"a${b",
+	"$*",
+	"$@",
+	"$1$2",
+	"${1}",
+	"${12}",
+	"$12",
+	"${*}",
+	"$-x",
+	"$.x",
+	"$ x",
+	"price: $5.00",
+	"${FOO}}",
+	"$}",
+	"${{FOO}}",
+	"日本$FOO語",
+}
+
+func mapping(name string) string {
+	switch name {
+	case "FOO":
+		return "foo"
+	case "BAR":
+		return "bar"
+	case "":
+		return "EMPTY"
+	}
+	return "<" + name + ">"
+}
+
+func TestExpand(t *testing.T) {
+	for _, tmpl := range osExpandTests {
+		t.Run(tmpl, func(t *testing.T) {
+			require.Equal(t, os.Expand(tmpl, mapping), Expand(tmpl, mapping))
+		})
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("OSEXPAND_TEST", "value")
+	tmpl := "$OSEXPAND_TEST ${OSEXPAND_TEST} $OSEXPAND_UNSET"
+	require.Equal(t, os.ExpandEnv(tmpl), ExpandEnv(tmpl))
+}
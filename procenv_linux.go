@@ -0,0 +1,47 @@
+package expando
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+)
+
+// ProcessEnvironment returns the environment of a running process by reading /proc/<pid>/environ. This is the
+// environment the process started with; changes the process made to its own environment after it started aren't
+// included. Reading the environment of another user's process needs the same permissions as ptrace, so it usually
+// fails unless the caller runs as the same user or as root. The error from that case wraps fs.ErrPermission.
+func ProcessEnvironment(pid int) (MapEnvironment, error) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/environ")
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return nil, fmt.Errorf("not allowed to read the environment of process %d, which needs the same user or root: %w", pid, err)
+		}
+		return nil, fmt.Errorf("reading the environment of process %d: %w", pid, err)
+	}
+	return parseEnviron(data), nil
+}
+
+// parseEnviron parses the NUL separated KEY=VALUE entries of a /proc/<pid>/environ file. When a key is repeated the
+// first value wins, like getenv.
+func parseEnviron(data []byte) MapEnvironment {
+	env := MapEnvironment{}
+	for len(data) > 0 {
+		var entry []byte
+		entry, data = data, nil
+		if i := bytes.IndexByte(entry, 0); i != -1 {
+			entry, data = entry[:i], entry[i+1:]
+		}
+		eq := bytes.IndexByte(entry, '=')
+		if eq < 1 {
+			continue
+		}
+		key := string(entry[:eq])
+		if _, ok := env[key]; !ok {
+			env[key] = string(entry[eq+1:])
+		}
+	}
+	return env
+}
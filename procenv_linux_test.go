@@ -0,0 +1,25 @@
+package expando
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessEnvironment(t *testing.T) {
+	env, err := ProcessEnvironment(os.Getpid())
+	require.NoError(t, err)
+	want, ok := os.LookupEnv("PATH")
+	got, gotOK := env.LookupEnv("PATH")
+	require.Equal(t, ok, gotOK)
+	require.Equal(t, want, got)
+
+	_, err = ProcessEnvironment(-1)
+	require.EqualError(t, err, "reading the environment of process -1: open /proc/-1/environ: no such file or directory")
+}
+
+func Test_parseEnviron(t *testing.T) {
+	env := parseEnviron([]byte("A=1\x00B=x=y\x00A=2\x00=bad\x00noequals\x00C=\x00"))
+	require.Equal(t, MapEnvironment{"A": "1", "B": "x=y", "C": ""}, env)
+}
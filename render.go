@@ -0,0 +1,198 @@
+package expando
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RenderManager renders template files to destination files and renders them again when their templates or the
+// values of their variables change. It is the core of a config reloader: Run polls the registered templates and
+// environments, and subscribers are told about every destination that is rewritten.
+type RenderManager struct {
+	expander    *Expander
+	mu          sync.Mutex
+	targets     []*renderTarget
+	subscribers []func(RenderEvent)
+}
+
+// RenderEvent describes a render of one destination
+type RenderEvent struct {
+	// Destination is the file that was rendered
+	Destination string
+
+	// Changed has the names of the variables whose values changed since the previous render, sorted. It is empty
+	// for the first render and when only the template changed.
+	Changed []string
+
+	// Err is the error from reading the template, expanding it or writing the destination. The destination isn't
+	// changed when Err isn't nil.
+	Err error
+}
+
+type renderTarget struct {
+	template    string
+	destination string
+	env         Environment
+	rendered    bool
+	output      []byte
+	values      map[string]recordedValue
+	err         error
+}
+
+// NewRenderManager returns a RenderManager that expands templates with expander. A nil expander behaves like Expand.
+func NewRenderManager(expander *Expander) *RenderManager {
+	if expander == nil {
+		expander = &Expander{}
+	}
+	return &RenderManager{expander: expander}
+}
+
+// Register adds a template file to render to destination with variables from env
+func (m *RenderManager) Register(template, destination string, env Environment) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.targets = append(m.targets, &renderTarget{
+		template:    template,
+		destination: destination,
+		env:         env,
+	})
+}
+
+// Subscribe adds a function to call with the RenderEvent for every destination that is rewritten or fails to
+// render. fn is called synchronously from Render after the render is done, so it shouldn't block, but it may call the
+// RenderManager's methods.
+func (m *RenderManager) Subscribe(fn func(RenderEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers = append(m.subscribers, fn)
+}
+
+// Render renders every registered template and writes the destinations whose output changed. A failure is reported
+// once until the target renders successfully again. It returns the first error.
+func (m *RenderManager) Render() error {
+	m.mu.Lock()
+	var firstErr error
+	var events []RenderEvent
+	for _, target := range m.targets {
+		event, notify := m.render(target)
+		if event.Err != nil && firstErr == nil {
+			firstErr = event.Err
+		}
+		if notify {
+			events = append(events, event)
+		}
+	}
+	subscribers := m.subscribers
+	m.mu.Unlock()
+	for _, event := range events {
+		for _, fn := range subscribers {
+			fn(event)
+		}
+	}
+	return firstErr
+}
+
+// Run calls Render immediately and then every interval until ctx is done. Errors are reported to subscribers and
+// don't stop Run. It returns ctx.Err(), or an error without rendering when interval isn't positive.
+func (m *RenderManager) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("render interval must be positive")
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Render() //nolint:errcheck // errors go to subscribers
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// render renders target and returns its event. notify is false when nothing changed.
+func (m *RenderManager) render(target *renderTarget) (event RenderEvent, notify bool) {
+	event.Destination = target.destination
+	fail := func(err error) (RenderEvent, bool) {
+		event.Err = err
+		// only report an error when it is new
+		notify := target.err == nil || target.err.Error() != err.Error()
+		target.err = err
+		return event, notify
+	}
+	tmpl, err := os.ReadFile(target.template)
+	if err != nil {
+		return fail(err)
+	}
+	env := NewRecorder(target.env)
+	output, err := m.expander.Expand(string(tmpl), env, nil)
+	if err != nil {
+		return fail(err)
+	}
+	if target.rendered && target.err == nil && bytes.Equal(output, target.output) {
+		target.values = env.values
+		return event, false
+	}
+	err = writeFileAtomic(target.destination, output)
+	if err != nil {
+		return fail(err)
+	}
+	if target.rendered {
+		event.Changed = changedValues(target.values, env.values)
+	}
+	target.rendered = true
+	target.output = output
+	target.values = env.values
+	target.err = nil
+	return event, true
+}
+
+// changedValues returns the sorted names of the variables that are different in a and b
+func changedValues(a, b map[string]recordedValue) []string {
+	var changed []string
+	for name, v := range b {
+		if prev, ok := a[name]; !ok || prev != v {
+			changed = append(changed, name)
+		}
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as filename and renames it to filename, so
+// readers never see a partly written file. It keeps the permissions of an existing file.
+func writeFileAtomic(filename string, data []byte) error {
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name()) //nolint:errcheck // the write already failed
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package expando
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderManager(t *testing.T) {
+	dir := t.TempDir()
+	tmplPath := filepath.Join(dir, "app.conf.tmpl")
+	dest := filepath.Join(dir, "app.conf")
+	require.NoError(t, os.WriteFile(tmplPath, []byte(`host=${HOST} port=${PORT|80}`), 0o600))
+	env := MapEnvironment{"HOST": "a"}
+
+	manager := NewRenderManager(nil)
+	manager.Register(tmplPath, dest, env)
+	var events []RenderEvent
+	manager.Subscribe(func(event RenderEvent) {
+		events = append(events, event)
+	})
+	requireDest := func(want string) {
+		t.Helper()
+		got, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+	}
+
+	require.NoError(t, manager.Render())
+	requireDest("host=a port=80")
+	require.Equal(t, []RenderEvent{{Destination: dest}}, events)
+
+	// nothing changed
+	events = nil
+	require.NoError(t, manager.Render())
+	require.Empty(t, events)
+
+	env["HOST"] = "b"
+	env["PORT"] = "443"
+	require.NoError(t, manager.Render())
+	requireDest("host=b port=443")
+	require.Equal(t, []RenderEvent{{Destination: dest, Changed: []string{"HOST", "PORT"}}}, events)
+
+	// errors are reported once and leave the destination alone
+	events = nil
+	require.NoError(t, os.WriteFile(tmplPath, []byte(`${`), 0o600))
+	require.Error(t, manager.Render())
+	require.Error(t, manager.Render())
+	requireDest("host=b port=443")
+	require.Len(t, events, 1)
+	require.Error(t, events[0].Err)
+
+	events = nil
+	require.NoError(t, os.WriteFile(tmplPath, []byte(`host=${HOST}`), 0o600))
+	require.NoError(t, manager.Render())
+	requireDest("host=b")
+	require.Equal(t, []RenderEvent{{Destination: dest, Changed: []string{"PORT"}}}, events)
+}
+
+func TestRenderManager_Run(t *testing.T) {
+	dir := t.TempDir()
+	tmplPath := filepath.Join(dir, "tmpl")
+	dest := filepath.Join(dir, "out")
+	require.NoError(t, os.WriteFile(tmplPath, []byte(`${A}`), 0o600))
+	manager := NewRenderManager(nil)
+	manager.Register(tmplPath, dest, MapEnvironment{"A": "1"})
+	rendered := make(chan RenderEvent, 1)
+	manager.Subscribe(func(event RenderEvent) {
+		rendered <- event
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- manager.Run(ctx, time.Millisecond)
+	}()
+	require.Equal(t, RenderEvent{Destination: dest}, <-rendered)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	require.EqualError(t, manager.Run(context.Background(), 0), "render interval must be positive")
+}
+
+func TestRenderManager_Subscribe(t *testing.T) {
+	dir := t.TempDir()
+	tmplPath := filepath.Join(dir, "tmpl")
+	require.NoError(t, os.WriteFile(tmplPath, []byte(`${A}`), 0o600))
+	manager := NewRenderManager(nil)
+	manager.Register(tmplPath, filepath.Join(dir, "a"), MapEnvironment{"A": "1"})
+	var destinations []string
+	// subscribers can call the manager's methods
+	manager.Subscribe(func(event RenderEvent) {
+		destinations = append(destinations, filepath.Base(event.Destination))
+		if len(destinations) == 1 {
+			manager.Register(tmplPath, filepath.Join(dir, "b"), MapEnvironment{"A": "2"})
+			manager.Subscribe(func(RenderEvent) {})
+		}
+	})
+	require.NoError(t, manager.Render())
+	require.NoError(t, manager.Render())
+	require.Equal(t, []string{"a", "b"}, destinations)
+}
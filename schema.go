@@ -0,0 +1,48 @@
+package expando
+
+import "encoding/json"
+
+// JSONSchema returns a JSON Schema describing the variables referenced by templates. The schema is for an object with
+// a string property for each variable. A variable is required unless every reference to it has a default value, and
+// the property's default is the default value from the first template by name that has one. When a variable has more
+// than one default value, all of them are listed in the property's examples.
+func JSONSchema(templates Templates) ([]byte, error) {
+	vars, err := collectVars(templates)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := jsonSchema{
+		Schema:     "https://json-schema.org/draft/2020-12/schema",
+		Type:       "object",
+		Properties: make(map[string]*schemaProperty, len(vars)),
+		Required:   []string{},
+	}
+	for _, v := range vars {
+		prop := &schemaProperty{Type: "string"}
+		if len(v.defaults) > 0 {
+			prop.Default = &v.defaults[0]
+		}
+		if len(v.defaults) > 1 {
+			prop.Examples = v.defaults
+		}
+		schema.Properties[v.name] = prop
+		if v.required {
+			schema.Required = append(schema.Required, v.name)
+		}
+	}
+	return json.MarshalIndent(&schema, "", "  ")
+}
+
+type jsonSchema struct {
+	Schema     string                     `json:"$schema"`
+	Type       string                     `json:"type"`
+	Properties map[string]*schemaProperty `json:"properties"`
+	Required   []string                   `json:"required"`
+}
+
+type schemaProperty struct {
+	Type     string   `json:"type"`
+	Default  *string  `json:"default,omitempty"`
+	Examples []string `json:"examples,omitempty"`
+}
@@ -0,0 +1,44 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchema(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		got, err := JSONSchema(map[string]string{
+			"b.conf": `${HOST}:${PORT|8080} ${MODE|}`,
+			"a.conf": `${PORT|80} $${NOT_A_VAR} ${USER|gopher} ${USER}`,
+		})
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "HOST": {"type": "string"},
+    "MODE": {"type": "string", "default": ""},
+    "PORT": {"type": "string", "default": "80", "examples": ["80", "8080"]},
+    "USER": {"type": "string", "default": "gopher"}
+  },
+  "required": ["HOST", "USER"]
+}`, string(got))
+	})
+
+	t.Run("no variables", func(t *testing.T) {
+		got, err := JSONSchema(nil)
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {},
+  "required": []
+}`, string(got))
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		_, err := JSONSchema(map[string]string{"a": "${"})
+		require.EqualError(t, err, `a: invalid syntax at position 2 of "${": unterminated`)
+	})
+}
@@ -0,0 +1,43 @@
+//go:build go1.21
+// +build go1.21
+
+package expando
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger makes the Expander log every template it expands to logger at debug level. Each record has the number of
+// variables expanded, the number of lookups that found no value and the duration of the expansion. Variable values are
+// never logged, so templates that expand secrets are safe to log. Records have a "template" attribute with the
+// ExpansionStats.TemplateID when the template was expanded with a context from ContextWithTemplateID. Use logger.With
+// to add attributes that are the same for every template an Expander expands.
+func WithLogger(logger *slog.Logger) Option {
+	return WithMetrics(slogMetrics{logger: logger})
+}
+
+// slogMetrics is the Metrics for WithLogger
+type slogMetrics struct {
+	logger *slog.Logger
+}
+
+func (m slogMetrics) ObserveExpansion(stats ExpansionStats) {
+	ctx := context.Background()
+	if !m.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := make([]slog.Attr, 0, 5)
+	if stats.TemplateID != "" {
+		attrs = append(attrs, slog.String("template", stats.TemplateID))
+	}
+	attrs = append(attrs,
+		slog.Int("variables", stats.Placeholders),
+		slog.Int("misses", stats.Misses),
+		slog.Duration("duration", stats.Duration),
+	)
+	if stats.Err != nil {
+		attrs = append(attrs, slog.String("error", stats.Err.Error()))
+	}
+	m.logger.LogAttrs(ctx, slog.LevelDebug, "expanded template", attrs...)
+}
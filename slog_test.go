@@ -0,0 +1,49 @@
+//go:build go1.21
+// +build go1.21
+
+package expando
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	expander := NewExpander(WithLogger(logger.With("app", "test")))
+	env := MapEnvironment{"SECRET": "hunter2"}
+
+	_, err := expander.Expand("${SECRET} ${missing|x}", env, nil)
+	require.NoError(t, err)
+	_, err = expander.Expand("${", env, nil)
+	require.Error(t, err)
+	tmpl, err := Parse("${SECRET}")
+	require.NoError(t, err)
+	ctx := ContextWithTemplateID(context.Background(), tmpl.Hash())
+	_, err = expander.ExpandContext(ctx, tmpl.String(), env, nil)
+	require.NoError(t, err)
+	require.Equal(t, `level=DEBUG msg="expanded template" app=test variables=2 misses=1
+level=DEBUG msg="expanded template" app=test variables=0 misses=0 error="invalid syntax at position 2 of \"${\": unterminated"
+level=DEBUG msg="expanded template" app=test template=`+tmpl.Hash()+` variables=1 misses=0
+`, buf.String())
+
+	// nothing is logged above debug level
+	buf.Reset()
+	expander = NewExpander(WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	_, err = expander.Expand("${SECRET}", env, nil)
+	require.NoError(t, err)
+	require.Empty(t, buf.String())
+}
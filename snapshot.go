@@ -0,0 +1,121 @@
+package expando
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Recorder is an Environment that records the variables looked up in another Environment, so a render can be audited
+// or reproduced later with the Snapshot. It is safe for concurrent use.
+type Recorder struct {
+	env    Environment
+	mu     sync.Mutex
+	values map[string]recordedValue
+}
+
+type recordedValue struct {
+	value string
+	ok    bool
+}
+
+// NewRecorder returns a Recorder that looks up variables in env
+func NewRecorder(env Environment) *Recorder {
+	return &Recorder{
+		env:    env,
+		values: map[string]recordedValue{},
+	}
+}
+
+// LookupEnv implements Environment.LookupEnv
+func (r *Recorder) LookupEnv(key string) (string, bool) {
+	val, ok := r.env.LookupEnv(key)
+	r.mu.Lock()
+	r.values[key] = recordedValue{value: val, ok: ok}
+	r.mu.Unlock()
+	return val, ok
+}
+
+// Snapshot returns the variables looked up so far. When a variable was looked up more than once, the snapshot has
+// the last value.
+func (r *Recorder) Snapshot() *Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := &Snapshot{Values: map[string]string{}}
+	for key, v := range r.values {
+		if v.ok {
+			s.Values[key] = v.value
+		} else {
+			s.Unset = append(s.Unset, key)
+		}
+	}
+	sort.Strings(s.Unset)
+	return s
+}
+
+// Snapshot is a record of the variables a render looked up. It is an Environment that replays the recorded values.
+// It marshals to JSON as is, or can be signed with Sign to detect tampering.
+type Snapshot struct {
+	// Values are the variables that were set
+	Values map[string]string `json:"values"`
+
+	// Unset are the names of the variables that were looked up and unset, sorted
+	Unset []string `json:"unset,omitempty"`
+}
+
+// LookupEnv implements Environment.LookupEnv. Variables that aren't in s.Values are unset.
+func (s *Snapshot) LookupEnv(key string) (string, bool) {
+	val, ok := s.Values[key]
+	return val, ok
+}
+
+// ErrSnapshotSignature is returned by OpenSnapshot when a snapshot's signature doesn't match
+var ErrSnapshotSignature = errors.New("invalid snapshot signature")
+
+type signedSnapshot struct {
+	Snapshot  json.RawMessage `json:"snapshot"`
+	Signature string          `json:"signature"`
+}
+
+// Sign returns s serialized as JSON with an HMAC-SHA256 signature made with key. Use OpenSnapshot to verify and read
+// it.
+func (s *Snapshot) Sign(key []byte) ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&signedSnapshot{
+		Snapshot:  data,
+		Signature: hex.EncodeToString(snapshotMAC(data, key)),
+	})
+}
+
+// OpenSnapshot returns the Snapshot from data returned by Sign. It returns ErrSnapshotSignature when the signature
+// wasn't made with key or the snapshot was changed after it was signed.
+func OpenSnapshot(data, key []byte) (*Snapshot, error) {
+	var signed signedSnapshot
+	err := json.Unmarshal(data, &signed)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := hex.DecodeString(signed.Signature)
+	if err != nil || !hmac.Equal(sig, snapshotMAC(signed.Snapshot, key)) {
+		return nil, ErrSnapshotSignature
+	}
+	var s Snapshot
+	err = json.Unmarshal(signed.Snapshot, &s)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func snapshotMAC(data, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data) //nolint:errcheck // hash writes don't fail
+	return mac.Sum(nil)
+}
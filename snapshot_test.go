@@ -0,0 +1,44 @@
+package expando
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(expandTestEnv)
+	tmpl := `${HOME}/${this} ${missing|none}`
+	want, err := Expand(tmpl, recorder, nil)
+	require.NoError(t, err)
+
+	snapshot := recorder.Snapshot()
+	require.Equal(t, &Snapshot{
+		Values: map[string]string{"HOME": "/usr/gopher", "this": "that"},
+		Unset:  []string{"missing"},
+	}, snapshot)
+
+	got, err := Expand(tmpl, snapshot, nil)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got))
+}
+
+func TestSnapshot_Sign(t *testing.T) {
+	key := []byte("secret")
+	snapshot := &Snapshot{Values: map[string]string{"A": "1"}, Unset: []string{"B"}}
+	data, err := snapshot.Sign(key)
+	require.NoError(t, err)
+
+	opened, err := OpenSnapshot(data, key)
+	require.NoError(t, err)
+	require.Equal(t, snapshot, opened)
+
+	_, err = OpenSnapshot(data, []byte("other"))
+	require.ErrorIs(t, err, ErrSnapshotSignature)
+
+	tampered := strings.Replace(string(data), `"A":"1"`, `"A":"2"`, 1)
+	require.NotEqual(t, string(data), tampered)
+	_, err = OpenSnapshot([]byte(tampered), key)
+	require.ErrorIs(t, err, ErrSnapshotSignature)
+}
@@ -0,0 +1,222 @@
+package expando
+
+import "sort"
+
+// StaticEnvironment is an Environment with a fixed set of variables. The variables are stored in a perfect hash table
+// that is built by NewStaticEnvironment, so a lookup is a hash of a few bytes of the key and a comparison with a
+// single entry. It is meant for hot loops that expand against the same small environment millions of times, where it
+// is faster than a MapEnvironment.
+type StaticEnvironment struct {
+	// sampled means keys are hashed with sampledHash instead of fullHash
+	sampled bool
+	// displacements has the displacement for each bucket of keys
+	displacements []uint32
+	entries       []staticEntry
+	// fallback has the variables when two keys have the same fullHash, so a perfect hash table can't be built
+	fallback map[string]string
+}
+
+type staticEntry struct {
+	key   string
+	value string
+	ok    bool
+}
+
+// NewStaticEnvironment returns a StaticEnvironment with the variables in values. Changes to values after
+// NewStaticEnvironment returns have no effect on the StaticEnvironment.
+func NewStaticEnvironment(values map[string]string) *StaticEnvironment {
+	return newStaticEnvironment(values, sampledHash, fullHash)
+}
+
+// newStaticEnvironment is NewStaticEnvironment with the hash functions as arguments for testing collisions
+func newStaticEnvironment(values map[string]string, sampled, full func(string) uint64) *StaticEnvironment {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	env := &StaticEnvironment{sampled: true}
+	hashes, ok := keyHashes(keys, sampled)
+	if !ok {
+		env.sampled = false
+		hashes, ok = keyHashes(keys, full)
+	}
+	if !ok {
+		// fullHash collisions are possible in theory but would take deliberately constructed keys
+		env.fallback = make(map[string]string, len(values))
+		for key, val := range values {
+			env.fallback[key] = val
+		}
+		return env
+	}
+	slots := 2
+	for slots < 2*len(keys) {
+		slots *= 2
+	}
+	for !env.build(keys, hashes, values, slots) {
+		slots *= 2
+	}
+	return env
+}
+
+// LookupEnv implements Environment.LookupEnv
+func (e *StaticEnvironment) LookupEnv(key string) (string, bool) {
+	if e.fallback != nil {
+		val, ok := e.fallback[key]
+		return val, ok
+	}
+	var h uint64
+	if e.sampled {
+		h = sampledHash(key)
+	} else {
+		h = fullHash(key)
+	}
+	d := e.displacements[h&uint64(len(e.displacements)-1)]
+	entry := &e.entries[displace(h, d)&uint64(len(e.entries)-1)]
+	if entry.ok && entry.key == key {
+		return entry.value, true
+	}
+	return "", false
+}
+
+// maxDisplacement is how many displacements build tries for a bucket before giving up on a table size
+const maxDisplacement = 1 << 16
+
+// build tries to place keys in a table with the given number of slots. It returns false when it can't find a
+// displacement for every bucket of keys.
+func (e *StaticEnvironment) build(keys []string, hashes []uint64, values map[string]string, slots int) bool {
+	bucketCount := 1
+	for bucketCount < len(keys)/2 {
+		bucketCount *= 2
+	}
+	buckets := make([][]int, bucketCount)
+	for i, h := range hashes {
+		b := h & uint64(bucketCount-1)
+		buckets[b] = append(buckets[b], i)
+	}
+	// place the largest buckets first while the table is emptiest
+	order := make([]int, bucketCount)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(buckets[order[i]]) > len(buckets[order[j]])
+	})
+
+	e.displacements = make([]uint32, bucketCount)
+	e.entries = make([]staticEntry, slots)
+	mask := uint64(slots - 1)
+	placed := make([]uint64, 0, len(keys))
+	for _, b := range order {
+		bucket := buckets[b]
+		if len(bucket) == 0 {
+			break
+		}
+		found := false
+		for d := uint32(0); d < maxDisplacement && !found; d++ {
+			placed = placed[:0]
+			found = true
+			for _, k := range bucket {
+				slot := displace(hashes[k], d) & mask
+				if e.entries[slot].ok || containsUint64(placed, slot) {
+					found = false
+					break
+				}
+				placed = append(placed, slot)
+			}
+			if found {
+				e.displacements[b] = d
+			}
+		}
+		if !found {
+			return false
+		}
+		for i, k := range bucket {
+			e.entries[placed[i]] = staticEntry{
+				key:   keys[k],
+				value: values[keys[k]],
+				ok:    true,
+			}
+		}
+	}
+	return true
+}
+
+// keyHashes returns the hashes of keys. It returns false if any two keys have the same hash.
+func keyHashes(keys []string, hash func(string) uint64) ([]uint64, bool) {
+	hashes := make([]uint64, len(keys))
+	seen := make(map[uint64]bool, len(keys))
+	for i, key := range keys {
+		h := hash(key)
+		if seen[h] {
+			return nil, false
+		}
+		seen[h] = true
+		hashes[i] = h
+	}
+	return hashes, true
+}
+
+// sampledHash hashes the length of key with its first and last 8 bytes, so it is cheap regardless of the length of
+// key. Keys longer than 16 bytes that only differ in the middle collide.
+func sampledHash(key string) uint64 {
+	n := len(key)
+	if n < 8 {
+		return mix(loadShort(key) ^ uint64(n)<<56)
+	}
+	return mix(load64(key, 0)*0x9e3779b97f4a7c15 ^ load64(key, n-8) ^ uint64(n))
+}
+
+// fullHash hashes every byte of key, 8 bytes at a time
+func fullHash(key string) uint64 {
+	n := len(key)
+	if n < 8 {
+		return mix(loadShort(key) ^ uint64(n)<<56)
+	}
+	h := uint64(n)
+	for i := 0; i+8 <= n; i += 8 {
+		h = (h ^ load64(key, i)) * 0x9e3779b97f4a7c15
+		h ^= h >> 29
+	}
+	return mix(h ^ load64(key, n-8))
+}
+
+// displace returns the slot hash for a key hash and a bucket displacement
+func displace(h uint64, d uint32) uint64 {
+	return ((h ^ uint64(d)) * 0xff51afd7ed558ccd) >> 32
+}
+
+// mix is the 64 bit finalizer from MurmurHash3
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// load64 returns the 8 bytes of s starting at i as a little endian uint64
+func load64(s string, i int) uint64 {
+	s = s[i : i+8]
+	return uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24 |
+		uint64(s[4])<<32 | uint64(s[5])<<40 | uint64(s[6])<<48 | uint64(s[7])<<56
+}
+
+// loadShort returns the bytes of s, which must be shorter than 8 bytes, as a little endian uint64
+func loadShort(s string) uint64 {
+	var x uint64
+	for i := 0; i < len(s); i++ {
+		x |= uint64(s[i]) << (8 * i)
+	}
+	return x
+}
+
+func containsUint64(s []uint64, v uint64) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
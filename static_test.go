@@ -0,0 +1,67 @@
+package expando
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticEnvironment(t *testing.T) {
+	for _, count := range []int{0, 1, 2, 10, 1_000} {
+		t.Run(fmt.Sprintf("%d variables", count), func(t *testing.T) {
+			values := map[string]string{}
+			for i := 0; i < count; i++ {
+				values[fmt.Sprintf("VAR_%d", i)] = fmt.Sprintf("value %d", i)
+			}
+			env := NewStaticEnvironment(values)
+			for key, want := range values {
+				got, ok := env.LookupEnv(key)
+				require.True(t, ok)
+				require.Equal(t, want, got)
+			}
+			for _, key := range []string{"", "VAR", "VAR_", "missing", fmt.Sprintf("VAR_%d", count)} {
+				got, ok := env.LookupEnv(key)
+				require.False(t, ok)
+				require.Empty(t, got)
+			}
+		})
+	}
+
+	t.Run("keys that differ in unsampled bytes", func(t *testing.T) {
+		values := map[string]string{
+			"ABCDEFGHIJ_1_KLMNOPQRST": "1",
+			"ABCDEFGHIJ_2_KLMNOPQRST": "2",
+			"":                        "empty",
+		}
+		env := NewStaticEnvironment(values)
+		require.False(t, env.sampled)
+		for key, want := range values {
+			got, ok := env.LookupEnv(key)
+			require.True(t, ok)
+			require.Equal(t, want, got)
+		}
+	})
+
+	t.Run("hash collisions", func(t *testing.T) {
+		values := map[string]string{"FOO": "foo", "BAR": "bar"}
+		collide := func(string) uint64 { return 1 }
+		env := newStaticEnvironment(values, collide, collide)
+		for key, want := range values {
+			got, ok := env.LookupEnv(key)
+			require.True(t, ok)
+			require.Equal(t, want, got)
+		}
+		_, ok := env.LookupEnv("BAZ")
+		require.False(t, ok)
+	})
+
+	t.Run("copies values", func(t *testing.T) {
+		values := map[string]string{"FOO": "bar"}
+		env := NewStaticEnvironment(values)
+		values["FOO"] = "baz"
+		got, ok := env.LookupEnv("FOO")
+		require.True(t, ok)
+		require.Equal(t, "bar", got)
+	})
+}
@@ -0,0 +1,122 @@
+package expando
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Placeholder is a variable reference in a template, as seen by a StyleRule
+type Placeholder struct {
+	Name       string
+	HasDefault bool
+	// Default is the default value without escape sequences
+	Default string
+}
+
+// StyleRule is a convention for StyleLint to enforce. Check returns a message describing how a placeholder breaks the
+// rule, or an empty string when it doesn't.
+type StyleRule struct {
+	Name  string
+	Check func(p Placeholder) string
+}
+
+// RequireDefaults is a StyleRule named "require-default" that requires every placeholder to have a default value
+func RequireDefaults() StyleRule {
+	return StyleRule{
+		Name: "require-default",
+		Check: func(p Placeholder) string {
+			if p.HasDefault {
+				return ""
+			}
+			return fmt.Sprintf("%s has no default value", p.Name)
+		},
+	}
+}
+
+// NamePattern is a StyleRule named "name-pattern" that requires variable names to match re
+func NamePattern(re *regexp.Regexp) StyleRule {
+	return StyleRule{
+		Name: "name-pattern",
+		Check: func(p Placeholder) string {
+			if re.MatchString(p.Name) {
+				return ""
+			}
+			return fmt.Sprintf("%s doesn't match %s", p.Name, re)
+		},
+	}
+}
+
+// MaxDefaultLength is a StyleRule named "max-default-length" that limits default values to n bytes
+func MaxDefaultLength(n int) StyleRule {
+	return StyleRule{
+		Name: "max-default-length",
+		Check: func(p Placeholder) string {
+			if len(p.Default) <= n {
+				return ""
+			}
+			return fmt.Sprintf("the default value of %s is %d bytes, more than %d", p.Name, len(p.Default), n)
+		},
+	}
+}
+
+// Finding is a placeholder that breaks a StyleRule
+type Finding struct {
+	Template string
+	// Offset is the byte offset of the placeholder's "${" in the template. Line and Column are the same position
+	// counted from 1, with Column in bytes.
+	Offset, Line, Column int
+	Rule                 string
+	Message              string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", f.Template, f.Line, f.Column, f.Message, f.Rule)
+}
+
+// StyleLint checks every placeholder in templates against rules. Findings are sorted by template name and position,
+// and by rule order for the same placeholder.
+func StyleLint(templates Templates, rules ...StyleRule) ([]Finding, error) {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var findings []Finding
+	for _, name := range names {
+		tmpl := templates[name]
+		s := scanner{tmpl: tmpl}
+		for {
+			start := s.pos
+			kind, err := s.next()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if kind == tokenEOF {
+				break
+			}
+			if kind != tokenVar {
+				continue
+			}
+			p := Placeholder{Name: s.name, HasDefault: s.hasDefault, Default: s.defaultValue()}
+			offset := start + len(s.text)
+			for _, rule := range rules {
+				msg := rule.Check(p)
+				if msg == "" {
+					continue
+				}
+				lineStart := strings.LastIndexByte(tmpl[:offset], '\n') + 1
+				findings = append(findings, Finding{
+					Template: name,
+					Offset:   offset,
+					Line:     strings.Count(tmpl[:offset], "\n") + 1,
+					Column:   offset - lineStart + 1,
+					Rule:     rule.Name,
+					Message:  msg,
+				})
+			}
+		}
+	}
+	return findings, nil
+}
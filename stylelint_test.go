@@ -0,0 +1,30 @@
+package expando
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStyleLint(t *testing.T) {
+	templates := map[string]string{
+		"b.conf": "host=${HOST|localhost}\nport=${port|8080}",
+		"a.conf": "$$ ${USER}",
+	}
+	findings, err := StyleLint(templates,
+		RequireDefaults(),
+		NamePattern(regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)),
+		MaxDefaultLength(4),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []Finding{
+		{Template: "a.conf", Offset: 3, Line: 1, Column: 4, Rule: "require-default", Message: "USER has no default value"},
+		{Template: "b.conf", Offset: 5, Line: 1, Column: 6, Rule: "max-default-length", Message: "the default value of HOST is 9 bytes, more than 4"},
+		{Template: "b.conf", Offset: 28, Line: 2, Column: 6, Rule: "name-pattern", Message: "port doesn't match ^[A-Z][A-Z0-9_]*$"},
+	}, findings)
+	require.Equal(t, "b.conf:2:6: port doesn't match ^[A-Z][A-Z0-9_]*$ (name-pattern)", findings[2].String())
+
+	_, err = StyleLint(map[string]string{"bad": `${`}, RequireDefaults())
+	require.Error(t, err)
+}
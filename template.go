@@ -0,0 +1,280 @@
+package expando
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+)
+
+// Template is a parsed template that can be executed many times without scanning its text again. Templates that are
+// rendered repeatedly with different environments, like per-request config, should be parsed once with Parse. A
+// Template is safe for concurrent use.
+type Template struct {
+	text     string
+	segments []segment
+}
+
+// segment is literal text followed by a variable. The last segment of a Template has no variable.
+type segment struct {
+	literal      string
+	name         string
+	hasDefault   bool
+	defaultValue string
+	// constraint is only set for templates parsed by an Expander WithConstraints
+	constraint string
+}
+
+// Parse parses tmpl for executing with the same syntax as Expand. It returns the same error Expand would when tmpl
+// isn't valid.
+func Parse(tmpl string) (*Template, error) {
+	return parseTokens(&scanner{tmpl: tmpl})
+}
+
+// parseTokens returns the Template for the tokens from s
+func parseTokens(s *scanner) (*Template, error) {
+	t := &Template{text: s.tmpl}
+	var literal string
+	for {
+		kind, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case tokenEOF:
+			if literal != "" || len(t.segments) == 0 {
+				t.segments = append(t.segments, segment{literal: literal})
+			}
+			return t, nil
+		case tokenLiteral:
+			// "$$" splits literal text into multiple tokens
+			literal += s.text
+		case tokenVar:
+			t.segments = append(t.segments, segment{
+				literal:      literal + s.text,
+				name:         s.name,
+				hasDefault:   s.hasDefault,
+				defaultValue: s.defaultValue(),
+				constraint:   s.constraint,
+			})
+			literal = ""
+		}
+	}
+}
+
+// Hash returns a hex encoded SHA-256 hash of the parsed Template. Templates with the same parsed structure have the same
+// hash even when they are written differently, like a "$" that doesn't start a variable written as "$" or "$$". An
+// empty default is part of the structure, so "${A}" and "${A|}" hash differently because they expand differently with
+// WithDefaultProvider or WithMissingMarker. The hash is stable across versions of expando, so it can be stored as a
+// cache key or compared to detect changes.
+func (t *Template) Hash() string {
+	sum := sha256.Sum256(t.appendSegments(nil))
+	return hex.EncodeToString(sum[:])
+}
+
+// appendSegments appends an encoding of the Template's segments to buf. Each segment is its length prefixed literal
+// text followed by a flag for whether it has a variable or a variable with a default, then the length prefixed name
+// and default value when it does. Changing the encoding changes Hash.
+func (t *Template) appendSegments(buf []byte) []byte {
+	buf = appendUvarint(buf, uint64(len(t.segments)))
+	for _, seg := range t.segments {
+		buf = appendString(buf, seg.literal)
+		switch {
+		case seg.name == "":
+			buf = append(buf, segmentLiteral)
+		case !seg.hasDefault:
+			buf = append(buf, segmentVar)
+			buf = appendString(buf, seg.name)
+		default:
+			buf = append(buf, segmentVarDefault)
+			buf = appendString(buf, seg.name)
+			buf = appendString(buf, seg.defaultValue)
+		}
+	}
+	return buf
+}
+
+// segment flags for appendSegments
+const (
+	segmentLiteral byte = iota
+	segmentVar
+	segmentVarDefault
+)
+
+// templateEncoding is the version of the MarshalBinary encoding
+const templateEncoding byte = 1
+
+// errTemplateEncoding is returned by UnmarshalBinary for data that wasn't written by MarshalBinary
+var errTemplateEncoding = errors.New("invalid Template encoding")
+
+// MarshalBinary implements encoding.BinaryMarshaler. The encoding has the parsed form of the Template along with its
+// text, so UnmarshalBinary doesn't need to parse it again.
+func (t *Template) MarshalBinary() ([]byte, error) {
+	buf := []byte{templateEncoding}
+	buf = appendString(buf, t.text)
+	return t.appendSegments(buf), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler for data from MarshalBinary
+func (t *Template) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != templateEncoding {
+		return errTemplateEncoding
+	}
+	d := decoder{data: data[1:]}
+	text := d.string()
+	segments := make([]segment, d.uvarint())
+	for i := range segments {
+		seg := &segments[i]
+		seg.literal = d.string()
+		switch d.byte() {
+		case segmentLiteral:
+		case segmentVar:
+			seg.name = d.string()
+		case segmentVarDefault:
+			seg.name = d.string()
+			seg.hasDefault = true
+			seg.defaultValue = d.string()
+		default:
+			d.err = errTemplateEncoding
+		}
+		if d.err != nil {
+			return d.err
+		}
+	}
+	if d.err != nil || len(d.data) != 0 || len(segments) == 0 {
+		return errTemplateEncoding
+	}
+	t.text = text
+	t.segments = segments
+	return nil
+}
+
+// decoder reads the encoding from appendSegments. Reads after an error return zero values.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) uvarint() uint64 {
+	x, n := binary.Uvarint(d.data)
+	if n <= 0 || x > uint64(len(d.data)) {
+		// every segment and string takes at least a byte, so a larger count can't be valid
+		d.err = errTemplateEncoding
+		d.data = nil
+		return 0
+	}
+	d.data = d.data[n:]
+	return x
+}
+
+func (d *decoder) byte() byte {
+	if len(d.data) == 0 {
+		d.err = errTemplateEncoding
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.err = errTemplateEncoding
+		d.data = nil
+		return ""
+	}
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	return s
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], x)
+	return append(buf, b[:n]...)
+}
+
+// String returns the text the Template was parsed from
+func (t *Template) String() string {
+	return t.text
+}
+
+// Execute appends the expansion of the Template to buf with values from lookupEnv, with the same result as Expand.
+// Parsing already found any syntax errors, so Execute can't fail.
+func (t *Template) Execute(lookupEnv Environment, buf []byte) []byte {
+	if buf == nil {
+		buf = make([]byte, 0, 2*len(t.text))
+	}
+	for i := range t.segments {
+		seg := &t.segments[i]
+		buf = append(buf, seg.literal...)
+		if seg.name == "" {
+			continue
+		}
+		val, ok := lookupEnv.LookupEnv(seg.name)
+		if !ok {
+			val = seg.defaultValue
+		}
+		buf = append(buf, val...)
+	}
+	return buf
+}
+
+// TemplateStats describes the size and complexity of a Template
+type TemplateStats struct {
+	// Literals is the number of runs of literal text
+	Literals int
+
+	// Placeholders is the number of variable references
+	Placeholders int
+
+	// Variables is the number of distinct variable names
+	Variables int
+
+	// Defaults is the number of Placeholders with a default value
+	Defaults int
+
+	// LongestDefault is the length of the longest default value
+	LongestDefault int
+
+	// Bytes is the length of the template text
+	Bytes int
+
+	// LiteralBytes is the length of the literal text in the output, with "$$" counted as the "$" it expands to
+	LiteralBytes int
+
+	// DefaultBytes is the total length of the default values
+	DefaultBytes int
+}
+
+// Stats returns the TemplateStats for the Template. Default value lengths are without escape sequences.
+func (t *Template) Stats() TemplateStats {
+	stats := TemplateStats{Bytes: len(t.text)}
+	names := map[string]bool{}
+	for _, seg := range t.segments {
+		if seg.literal != "" {
+			stats.Literals++
+			stats.LiteralBytes += len(seg.literal)
+		}
+		if seg.name == "" {
+			continue
+		}
+		stats.Placeholders++
+		names[seg.name] = true
+		if seg.hasDefault {
+			stats.Defaults++
+			stats.DefaultBytes += len(seg.defaultValue)
+			if len(seg.defaultValue) > stats.LongestDefault {
+				stats.LongestDefault = len(seg.defaultValue)
+			}
+		}
+	}
+	stats.Variables = len(names)
+	return stats
+}
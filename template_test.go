@@ -0,0 +1,105 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, td := range expandTests {
+		t.Run(td.in, func(t *testing.T) {
+			tmpl, err := Parse(td.in)
+			if td.err != nil {
+				require.EqualError(t, err, td.err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.in, tmpl.String())
+			require.Equal(t, td.out, string(tmpl.Execute(expandTestEnv, nil)))
+		})
+	}
+}
+
+func TestTemplate_Execute(t *testing.T) {
+	tmpl, err := Parse(`a $$${HOME} b ${missing|x\}y} $$ c`)
+	require.NoError(t, err)
+	require.Equal(t, []segment{
+		{literal: "a $", name: "HOME"},
+		{literal: " b ", name: "missing", hasDefault: true, defaultValue: "x}y"},
+		{literal: " $ c"},
+	}, tmpl.segments)
+
+	buf := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = tmpl.Execute(expandTestEnv, buf[:0])
+	})
+	require.Zero(t, allocs)
+	require.Equal(t, `a $/usr/gopher b x}y $ c`, string(buf))
+}
+
+func TestTemplate_Stats(t *testing.T) {
+	tmpl, err := Parse(`a $$${HOME}${HOME|x\}y} b ${PORT|80} ${HOME}`)
+	require.NoError(t, err)
+	require.Equal(t, TemplateStats{
+		Literals:       3,
+		Placeholders:   4,
+		Variables:      2,
+		Defaults:       2,
+		LongestDefault: 3,
+		Bytes:          44,
+		LiteralBytes:   len("a $") + len(" b ") + len(" "),
+		DefaultBytes:   len("x}y") + len("80"),
+	}, tmpl.Stats())
+
+	tmpl, err = Parse(``)
+	require.NoError(t, err)
+	require.Equal(t, TemplateStats{}, tmpl.Stats())
+}
+
+func TestTemplate_Hash(t *testing.T) {
+	hash := func(tmpl string) string {
+		t.Helper()
+		parsed, err := Parse(tmpl)
+		require.NoError(t, err)
+		return parsed.Hash()
+	}
+	want := hash(`a $$ ${HOME|x\}y}`)
+	require.Len(t, want, 64)
+	// changing the encoding would break stored cache keys
+	require.Equal(t, "ee2e711982070527f28a2de9d1ceff824615ffa67c049aaf5c8cf9798193d93f", want)
+	require.Equal(t, want, hash(`a $$ ${HOME|x\}y}`))
+	// "$" that doesn't start a variable is literal with or without escaping
+	require.Equal(t, want, hash(`a $ ${HOME|x\}y}`))
+
+	for _, other := range []string{`a $$ ${HOME|x}`, `a $$ ${HOME}`, `a $$${HOME|x\}y}`, `a $$ ${HOMER|x\}y}`, `a $$ ${HOME|x\}y}z`} {
+		require.NotEqual(t, want, hash(other), other)
+	}
+	require.NotEqual(t, hash(`${A}`), hash(`${A|}`))
+	require.NotEqual(t, hash(`${A}b`), hash(`${Ab}`))
+}
+
+func TestTemplate_MarshalBinary(t *testing.T) {
+	for _, tmpl := range []string{``, `plain`, `a $$${HOME} b ${missing|x\}y} $$ c`, `${A|}${B}`} {
+		parsed, err := Parse(tmpl)
+		require.NoError(t, err)
+		data, err := parsed.MarshalBinary()
+		require.NoError(t, err)
+		var got Template
+		require.NoError(t, got.UnmarshalBinary(data))
+		require.Equal(t, *parsed, got)
+		require.Equal(t, tmpl, got.String())
+		require.Equal(t, string(parsed.Execute(expandTestEnv, nil)), string(got.Execute(expandTestEnv, nil)))
+
+		// every truncation is an error
+		for i := 0; i < len(data); i++ {
+			require.Error(t, got.UnmarshalBinary(data[:i]))
+		}
+		require.Error(t, got.UnmarshalBinary(append(data, 0)))
+	}
+
+	var got Template
+	require.EqualError(t, got.UnmarshalBinary([]byte{2}), "invalid Template encoding")
+	require.EqualError(t, got.UnmarshalBinary([]byte{1, 0, 1, 0, 9}), "invalid Template encoding")
+	require.Error(t, got.UnmarshalBinary([]byte{1, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}))
+}
@@ -0,0 +1,72 @@
+package expando
+
+import "context"
+
+// ContextEnvironment is an Environment that can use the context passed to ExpandContext or ExpandStreamContext for its
+// lookups, for example to cancel requests to a remote service or to record them in the caller's trace. Methods
+// without a context call LookupEnv.
+type ContextEnvironment interface {
+	Environment
+	LookupEnvContext(ctx context.Context, key string) (string, bool)
+}
+
+// Tracer traces the lookups of an Expander configured WithTracer. StartLookup is called before each call to the
+// Environment with the context passed to ExpandContext or ExpandStreamContext. It returns the context for the lookup,
+// which is passed on to a ContextEnvironment, and a function that is called with whether the lookup found a value.
+// Implementations must be safe for concurrent use when the Expander is used concurrently.
+type Tracer interface {
+	StartLookup(ctx context.Context, name string) (context.Context, func(found bool))
+}
+
+// WithTracer makes the Expander trace lookups with t. Only lookups made by ExpandContext and ExpandStreamContext are
+// traced because the other methods have no context to trace them in. With WithMemoizedLookups, only the first lookup
+// of each variable reaches the Environment and is traced. The github.com/willabides/expando/expandootel module has a
+// Tracer that records lookups as OpenTelemetry spans.
+func WithTracer(t Tracer) Option {
+	return func(e *Expander) {
+		e.tracer = t
+	}
+}
+
+// contextEnv is an Environment that passes ctx to env and traces lookups with tracer
+type contextEnv struct {
+	ctx    context.Context
+	env    Environment
+	tracer Tracer
+}
+
+func (c *contextEnv) LookupEnv(key string) (string, bool) {
+	ctx := c.ctx
+	var done func(bool)
+	if c.tracer != nil {
+		ctx, done = c.tracer.StartLookup(ctx, key)
+	}
+	var val string
+	var ok bool
+	if env, isCtx := c.env.(ContextEnvironment); isCtx {
+		val, ok = env.LookupEnvContext(ctx, key)
+	} else {
+		val, ok = c.env.LookupEnv(key)
+	}
+	if done != nil {
+		done(ok)
+	}
+	return val, ok
+}
+
+// newContextExpansion is newExpansion for methods that take a context
+func (e *Expander) newContextExpansion(ctx context.Context, lookupEnv Environment) expansion {
+	if _, ok := lookupEnv.(ContextEnvironment); ok || e.tracer != nil {
+		lookupEnv = &contextEnv{
+			ctx:    ctx,
+			env:    lookupEnv,
+			tracer: e.tracer,
+		}
+	}
+	x := e.newExpansion(lookupEnv)
+	x.ctx = ctx
+	if len(e.metrics) > 0 {
+		x.stats.TemplateID, _ = ctx.Value(templateIDKey{}).(string)
+	}
+	return x
+}
@@ -0,0 +1,97 @@
+package expando
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type ctxKey struct{}
+
+type tracerFunc func(ctx context.Context, name string) (context.Context, func(found bool))
+
+func (fn tracerFunc) StartLookup(ctx context.Context, name string) (context.Context, func(found bool)) {
+	return fn(ctx, name)
+}
+
+// contextEnvFunc is a ContextEnvironment that records the value of ctxKey in the context of each lookup
+type contextEnvFunc func(ctx context.Context, key string) (string, bool)
+
+func (fn contextEnvFunc) LookupEnv(key string) (string, bool) {
+	return fn(context.Background(), key)
+}
+
+func (fn contextEnvFunc) LookupEnvContext(ctx context.Context, key string) (string, bool) {
+	return fn(ctx, key)
+}
+
+func TestWithTracer(t *testing.T) {
+	var traced []string
+	tracer := tracerFunc(func(ctx context.Context, name string) (context.Context, func(found bool)) {
+		return context.WithValue(ctx, ctxKey{}, "span "+name), func(found bool) {
+			traced = append(traced, fmt.Sprintf("%s %t", name, found))
+		}
+	})
+	var lookups []interface{}
+	env := contextEnvFunc(func(ctx context.Context, key string) (string, bool) {
+		lookups = append(lookups, ctx.Value(ctxKey{}))
+		return expandTestEnv.LookupEnv(key)
+	})
+	tmpl := `${HOME} ${missing|a} ${HOME}`
+	want := `/usr/gopher a /usr/gopher`
+
+	t.Run("ExpandContext", func(t *testing.T) {
+		traced, lookups = nil, nil
+		expander := NewExpander(WithTracer(tracer))
+		result, err := expander.ExpandContext(context.Background(), tmpl, env, nil)
+		require.NoError(t, err)
+		require.Equal(t, want, string(result))
+		require.Equal(t, []string{"HOME true", "missing false", "HOME true"}, traced)
+		require.Equal(t, []interface{}{"span HOME", "span missing", "span HOME"}, lookups)
+	})
+
+	t.Run("ExpandStreamContext", func(t *testing.T) {
+		traced, lookups = nil, nil
+		expander := NewExpander(WithTracer(tracer))
+		var buf bytes.Buffer
+		err := expander.ExpandStreamContext(context.Background(), &buf, strings.NewReader(tmpl), env)
+		require.NoError(t, err)
+		require.Equal(t, want, buf.String())
+		require.Equal(t, []string{"HOME true", "missing false", "HOME true"}, traced)
+	})
+
+	t.Run("memoized lookups are traced once", func(t *testing.T) {
+		traced, lookups = nil, nil
+		expander := NewExpander(WithTracer(tracer), WithMemoizedLookups())
+		_, err := expander.ExpandContext(context.Background(), tmpl, env, nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"HOME true", "missing false"}, traced)
+	})
+
+	t.Run("Expand isn't traced", func(t *testing.T) {
+		traced, lookups = nil, nil
+		expander := NewExpander(WithTracer(tracer))
+		result, err := expander.Expand(tmpl, env, nil)
+		require.NoError(t, err)
+		require.Equal(t, want, string(result))
+		require.Empty(t, traced)
+		require.Equal(t, []interface{}{nil, nil, nil}, lookups)
+	})
+}
+
+func TestContextEnvironment(t *testing.T) {
+	var lookups []interface{}
+	env := contextEnvFunc(func(ctx context.Context, key string) (string, bool) {
+		lookups = append(lookups, ctx.Value(ctxKey{}))
+		return expandTestEnv.LookupEnv(key)
+	})
+	ctx := context.WithValue(context.Background(), ctxKey{}, "caller")
+	result, err := ExpandContext(ctx, `${HOME}/${H}`, env, nil)
+	require.NoError(t, err)
+	require.Equal(t, `/usr/gopher/(Value of H)`, string(result))
+	require.Equal(t, []interface{}{"caller", "caller"}, lookups)
+}
@@ -0,0 +1,176 @@
+package expando
+
+import (
+	"fmt"
+	"sort"
+)
+
+// varRef is a reference to a variable in a template
+type varRef struct {
+	name         string
+	hasDefault   bool
+	defaultValue string
+}
+
+// templateVars returns the variables referenced by tmpl in the order they appear
+func templateVars(tmpl string) ([]varRef, error) {
+	var refs []varRef
+	s := scanner{tmpl: tmpl}
+	for {
+		kind, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case tokenEOF:
+			return refs, nil
+		case tokenVar:
+			refs = append(refs, varRef{
+				name:         s.name,
+				hasDefault:   s.hasDefault,
+				defaultValue: s.defaultValue(),
+			})
+		}
+	}
+}
+
+// Templates are template text by template name for the functions that work on a set of templates like Lint and
+// DependencyGraph. They visit templates in order by name, and when a template isn't valid they return an error that
+// starts with the template's name.
+type Templates map[string]string
+
+// eachTemplateVar calls fn with every variable referenced by templates in order by template name
+func eachTemplateVar(templates Templates, fn func(tmplName string, ref varRef)) error {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		refs, err := templateVars(templates[name])
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, ref := range refs {
+			fn(name, ref)
+		}
+	}
+	return nil
+}
+
+// templateVar describes all the references to a variable in a set of templates
+type templateVar struct {
+	name string
+	// required means at least one reference has no default value
+	required bool
+	// defaults has the distinct default values in the order they are found
+	defaults []string
+	// templates has the names of the templates that reference the variable, sorted
+	templates []string
+	// requiredIn has the names of the templates that reference the variable without a default value, sorted
+	requiredIn []string
+}
+
+// collectVars returns the variables referenced by templates sorted by name
+func collectVars(templates Templates) ([]*templateVar, error) {
+	c := varCollector{}
+	err := eachTemplateVar(templates, c.add)
+	if err != nil {
+		return nil, err
+	}
+	return c.sorted(), nil
+}
+
+// collectTemplateVars is collectVars for a single template with the name "". It returns the error from scanning tmpl.
+func collectTemplateVars(tmpl string) ([]*templateVar, error) {
+	refs, err := templateVars(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	c := varCollector{}
+	for _, ref := range refs {
+		c.add("", ref)
+	}
+	return c.sorted(), nil
+}
+
+// varCollector combines references to variables into templateVars by name
+type varCollector map[string]*templateVar
+
+// add adds a reference from the template named tmplName. References must be added in order by template name.
+func (c varCollector) add(tmplName string, ref varRef) {
+	v := c[ref.name]
+	if v == nil {
+		v = &templateVar{name: ref.name}
+		c[ref.name] = v
+	}
+	if len(v.templates) == 0 || v.templates[len(v.templates)-1] != tmplName {
+		v.templates = append(v.templates, tmplName)
+	}
+	if !ref.hasDefault {
+		v.required = true
+		if len(v.requiredIn) == 0 || v.requiredIn[len(v.requiredIn)-1] != tmplName {
+			v.requiredIn = append(v.requiredIn, tmplName)
+		}
+		return
+	}
+	if !containsString(v.defaults, ref.defaultValue) {
+		v.defaults = append(v.defaults, ref.defaultValue)
+	}
+}
+
+// sorted returns the collected variables sorted by name
+func (c varCollector) sorted() []*templateVar {
+	vars := make([]*templateVar, 0, len(c))
+	for _, v := range c {
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool {
+		return vars[i].name < vars[j].name
+	})
+	return vars
+}
+
+// Var is a variable referenced by a template
+type Var struct {
+	Name string
+
+	// Required means at least one reference to the variable has no default value, so the variable needs to be set
+	Required bool
+
+	// Defaults are the distinct default values of the references that have one, in the order they appear
+	Defaults []string
+}
+
+// ListVars returns the variables referenced by tmpl sorted by name, so a program can check that an environment
+// defines everything a template needs before expanding it. It returns the same error Expand would when tmpl isn't
+// valid.
+func ListVars(tmpl string) ([]Var, error) {
+	vars, err := collectTemplateVars(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return exportVars(vars), nil
+}
+
+// Vars returns the variables referenced by the Template sorted by name like ListVars
+func (t *Template) Vars() []Var {
+	c := varCollector{}
+	for _, seg := range t.segments {
+		if seg.name != "" {
+			c.add("", varRef{name: seg.name, hasDefault: seg.hasDefault, defaultValue: seg.defaultValue})
+		}
+	}
+	return exportVars(c.sorted())
+}
+
+func exportVars(vars []*templateVar) []Var {
+	if len(vars) == 0 {
+		return nil
+	}
+	exported := make([]Var, len(vars))
+	for i, v := range vars {
+		exported[i] = Var{Name: v.name, Required: v.required, Defaults: v.defaults}
+	}
+	return exported
+}
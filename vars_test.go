@@ -0,0 +1,58 @@
+package expando
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_templateVars(t *testing.T) {
+	refs, err := templateVars(`${a} $${b} ${c|} ${d|x\}y} ${a}`)
+	require.NoError(t, err)
+	require.Equal(t, []varRef{
+		{name: "a"},
+		{name: "c", hasDefault: true},
+		{name: "d", hasDefault: true, defaultValue: "x}y"},
+		{name: "a"},
+	}, refs)
+}
+
+func Test_collectVars(t *testing.T) {
+	vars, err := collectVars(map[string]string{
+		"b": `${x|1} ${y}`,
+		"a": `${x|2} ${x|1} ${x}`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*templateVar{
+		{name: "x", required: true, defaults: []string{"2", "1"}, templates: []string{"a", "b"}, requiredIn: []string{"a"}},
+		{name: "y", required: true, templates: []string{"b"}, requiredIn: []string{"b"}},
+	}, vars)
+
+	// the generators built on collectVars report invalid templates by name
+	_, err = collectVars(map[string]string{"a": `$${not_a_var}`, "b": `${`})
+	require.EqualError(t, err, `b: invalid syntax at position 2 of "${": unterminated`)
+}
+
+func TestListVars(t *testing.T) {
+	tmpl := `${PORT|80} ${HOST} $${ESCAPED} ${PORT|8080} ${HOST|localhost} ${PORT|80} ${EMPTY|}`
+	want := []Var{
+		{Name: "EMPTY", Defaults: []string{""}},
+		{Name: "HOST", Required: true, Defaults: []string{"localhost"}},
+		{Name: "PORT", Defaults: []string{"80", "8080"}},
+	}
+	vars, err := ListVars(tmpl)
+	require.NoError(t, err)
+	require.Equal(t, want, vars)
+
+	parsed, err := Parse(tmpl)
+	require.NoError(t, err)
+	require.Equal(t, want, parsed.Vars())
+
+	vars, err = ListVars(`no vars`)
+	require.NoError(t, err)
+	require.Empty(t, vars)
+
+	_, err = ListVars(`${`)
+	_, wantErr := Expand(`${`, MapEnvironment{}, nil)
+	require.Equal(t, wantErr, err)
+}
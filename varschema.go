@@ -0,0 +1,141 @@
+package expando
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VarSpec declares a variable for a VarSchema
+type VarSpec struct {
+	Name string
+
+	// Type is the type values must have. It is one of "int", "bool" and "url" like the named constraints of
+	// WithConstraints. An empty Type or "string" allows any value.
+	Type string
+
+	// Required means the variable must have a value. An empty value counts as a value.
+	Required bool
+
+	// Allowed are the allowed values. Any value is allowed when Allowed is empty.
+	Allowed []string
+}
+
+// VarSchema declares the variables templates expect so they can be validated before expansion
+type VarSchema []VarSpec
+
+// VarProblem is a variable that doesn't satisfy its VarSpec. Problem never includes the variable's value, which may be
+// a secret.
+type VarProblem struct {
+	Name    string
+	Problem string
+}
+
+// SchemaError is returned by VarSchema's methods with every problem they find
+type SchemaError struct {
+	Problems []VarProblem
+}
+
+func (e *SchemaError) Error() string {
+	var sb strings.Builder
+	if len(e.Problems) == 1 {
+		sb.WriteString("1 invalid variable:")
+	} else {
+		fmt.Fprintf(&sb, "%d invalid variables:", len(e.Problems))
+	}
+	for _, p := range e.Problems {
+		fmt.Fprintf(&sb, "\n  %s: %s", p.Name, p.Problem)
+	}
+	return sb.String()
+}
+
+// Validate returns a *SchemaError when the variables in env don't satisfy the schema
+func (s VarSchema) Validate(env Environment) error {
+	var problems []VarProblem
+	for _, spec := range s {
+		val, ok := env.LookupEnv(spec.Name)
+		switch {
+		case ok:
+			problems = spec.check(problems, val)
+		case spec.Required:
+			problems = append(problems, VarProblem{Name: spec.Name, Problem: "required but unset"})
+		}
+	}
+	return schemaError(problems)
+}
+
+// ValidateTemplate is like Validate, but it checks the values the variables referenced by tmpl resolve to, including
+// default values, instead of the values in env. Variables tmpl doesn't reference are validated like Validate does.
+// It returns the error from scanning tmpl when tmpl isn't valid.
+func (s VarSchema) ValidateTemplate(tmpl string, env Environment) error {
+	vars, err := collectTemplateVars(tmpl)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]*templateVar, len(vars))
+	for _, v := range vars {
+		byName[v.name] = v
+	}
+	var problems []VarProblem
+	for _, spec := range s {
+		val, ok := env.LookupEnv(spec.Name)
+		if ok {
+			problems = spec.check(problems, val)
+			continue
+		}
+		v := byName[spec.Name]
+		if spec.Required && (v == nil || v.required) {
+			problems = append(problems, VarProblem{Name: spec.Name, Problem: "required but unset"})
+		}
+		if v == nil {
+			continue
+		}
+		for _, d := range v.defaults {
+			problems = spec.check(problems, d)
+		}
+	}
+	return schemaError(problems)
+}
+
+// check appends the problems with val to problems
+func (spec *VarSpec) check(problems []VarProblem, val string) []VarProblem {
+	if spec.Type != "" && spec.Type != "string" {
+		check, ok := namedConstraints[spec.Type]
+		switch {
+		case !ok:
+			return append(problems, VarProblem{Name: spec.Name, Problem: fmt.Sprintf("unknown type %q", spec.Type)})
+		case !check(val):
+			problems = append(problems, VarProblem{Name: spec.Name, Problem: "value is not a valid " + spec.Type})
+		}
+	}
+	if len(spec.Allowed) > 0 && !containsString(spec.Allowed, val) {
+		problems = append(problems, VarProblem{
+			Name:    spec.Name,
+			Problem: "value is not one of " + strings.Join(quoteAll(spec.Allowed), ", "),
+		})
+	}
+	return problems
+}
+
+func schemaError(problems []VarProblem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &SchemaError{Problems: problems}
+}
+
+func containsString(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(s []string) []string {
+	quoted := make([]string, len(s))
+	for i, v := range s {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return quoted
+}
@@ -0,0 +1,53 @@
+package expando
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testVarSchema = VarSchema{
+	{Name: "PORT", Type: "int", Required: true},
+	{Name: "DEBUG", Type: "bool"},
+	{Name: "LEVEL", Allowed: []string{"debug", "info"}},
+	{Name: "HOST", Required: true},
+}
+
+func TestVarSchema_Validate(t *testing.T) {
+	err := testVarSchema.Validate(MapEnvironment{"PORT": "80", "HOST": "", "LEVEL": "info"})
+	require.NoError(t, err)
+
+	err = testVarSchema.Validate(MapEnvironment{"PORT": "x", "DEBUG": "maybe", "LEVEL": "warn"})
+	require.Equal(t, &SchemaError{Problems: []VarProblem{
+		{Name: "PORT", Problem: `value is not a valid int`},
+		{Name: "DEBUG", Problem: `value is not a valid bool`},
+		{Name: "LEVEL", Problem: `value is not one of "debug", "info"`},
+		{Name: "HOST", Problem: "required but unset"},
+	}}, err)
+	require.EqualError(t, err, `4 invalid variables:
+  PORT: value is not a valid int
+  DEBUG: value is not a valid bool
+  LEVEL: value is not one of "debug", "info"
+  HOST: required but unset`)
+
+	err = VarSchema{{Name: "A", Type: "float"}}.Validate(MapEnvironment{"A": "1"})
+	require.EqualError(t, err, "1 invalid variable:\n  A: unknown type \"float\"")
+}
+
+func TestVarSchema_ValidateTemplate(t *testing.T) {
+	err := testVarSchema.ValidateTemplate(`${HOST|localhost}:${PORT|80} ${LEVEL|info}`, MapEnvironment{})
+	require.NoError(t, err)
+
+	err = testVarSchema.ValidateTemplate(`${HOST}:${PORT|http} ${LEVEL|info} ${LEVEL|warn}`, MapEnvironment{"PORT": "8080"})
+	require.Equal(t, &SchemaError{Problems: []VarProblem{
+		{Name: "LEVEL", Problem: `value is not one of "debug", "info"`},
+		{Name: "HOST", Problem: "required but unset"},
+	}}, err)
+
+	err = testVarSchema.ValidateTemplate(`${0}`, MapEnvironment{})
+	_, wantErr := Expand(`${0}`, MapEnvironment{}, nil)
+	require.Equal(t, wantErr, err)
+	var schemaErr *SchemaError
+	require.False(t, errors.As(err, &schemaErr))
+}